@@ -0,0 +1,83 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// names of the built-in bot commands
+var builtinCommands = []string{"help", "topic", "users"}
+
+func (c *Client) registerBuiltinCommands() {
+	for _, cmd := range []BotCommand{
+		{
+			Name: "help",
+			Help: "list the commands or describe one",
+			Args: []ArgSpec{{Name: "command", Optional: true}},
+			Run:  c.commandHelp,
+		},
+		{
+			Name:  "topic",
+			Help:  "show the channel topic",
+			Scope: ScopeChannel,
+			Run:   c.commandTopic,
+		},
+		{
+			Name:    "users",
+			Aliases: []string{"names"},
+			Help:    "list the users in the channel",
+			Scope:   ScopeChannel,
+			Run:     c.commandUsers,
+		},
+	} {
+		if err := c.router.Register(cmd); err != nil {
+			c.logger.Error("error registering command", "command", cmd.Name, "error", err)
+		}
+	}
+}
+
+func (c *Client) commandHelp(ctx *CommandContext) error {
+	prefix := c.router.prefix
+
+	if ctx.Has("command") {
+		cmd, ok := c.router.Lookup(strings.TrimPrefix(ctx.String("command"), prefix))
+		if !ok {
+			return ctx.Reply("Unknown command: " + ctx.String("command"))
+		}
+
+		help := cmd.Usage(prefix)
+		if cmd.Help != "" {
+			help += " - " + cmd.Help
+		}
+		if len(cmd.Aliases) > 0 {
+			help += " (aliases: " + strings.Join(cmd.Aliases, ", ") + ")"
+		}
+		return ctx.Reply(help)
+	}
+
+	cmds := c.router.Commands(ctx.Private())
+	names := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		names = append(names, prefix+cmd.Name)
+	}
+	return ctx.Reply(fmt.Sprintf("Commands: %s - %shelp <command> for details",
+		strings.Join(names, ", "), prefix))
+}
+
+func (c *Client) commandTopic(ctx *CommandContext) error {
+	if err := ctx.Reply("Topic: " + ctx.Channel.Topic); err != nil {
+		return fmt.Errorf("error sending topic: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) commandUsers(ctx *CommandContext) error {
+	users := make([]string, 0)
+	for _, user := range ctx.Channel.Users {
+		users = append(users, user.Nick)
+	}
+	if err := ctx.Reply("Users: " + strings.Join(users, ", ")); err != nil {
+		return fmt.Errorf("error sending names: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package client
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// capabilities requested when the config doesn't list any
+var defaultCaps = []string{"message-tags", "server-time", "multi-prefix"}
+
+type capState struct {
+	// wanted capabilities, requested whenever the server offers them
+	wanted map[string]bool
+	// available capabilities advertised by the server, with their values
+	available map[string]string
+	// enabledMu guards enabled on its own so that HasCap can be called
+	// while sending, with the client lock held
+	enabledMu sync.RWMutex
+	// enabled capabilities acknowledged by the server
+	enabled map[string]string
+
+	// negotiating is true until CAP END is sent during registration
+	negotiating bool
+	// ls accumulates a multi-line CAP LS reply
+	ls map[string]string
+	// pending counts the CAP REQ without ACK or NAK yet
+	pending int
+}
+
+func newCapState(wanted []string) *capState {
+	s := &capState{
+		wanted:    make(map[string]bool),
+		available: make(map[string]string),
+		enabled:   make(map[string]string),
+	}
+	for _, name := range wanted {
+		s.wanted[name] = true
+	}
+	return s
+}
+
+// reset forgets what was negotiated on a previous connection
+func (s *capState) reset() {
+	s.available = make(map[string]string)
+	s.enabledMu.Lock()
+	s.enabled = make(map[string]string)
+	s.enabledMu.Unlock()
+	s.negotiating = false
+	s.ls = nil
+	s.pending = 0
+}
+
+func (s *capState) isEnabled(name string) bool {
+	s.enabledMu.RLock()
+	defer s.enabledMu.RUnlock()
+	_, ok := s.enabled[name]
+	return ok
+}
+
+func (s *capState) enable(name, value string) {
+	s.enabledMu.Lock()
+	defer s.enabledMu.Unlock()
+	s.enabled[name] = value
+}
+
+func (s *capState) disable(name string) {
+	s.enabledMu.Lock()
+	defer s.enabledMu.Unlock()
+	delete(s.enabled, name)
+}
+
+func (s *capState) enabledCaps() map[string]string {
+	s.enabledMu.RLock()
+	defer s.enabledMu.RUnlock()
+	return maps.Clone(s.enabled)
+}
+
+// parseCapList parses "a b=1 c" into names and values
+func parseCapList(in string) map[string]string {
+	caps := make(map[string]string)
+	for _, item := range strings.Fields(in) {
+		name, value, _ := strings.Cut(item, "=")
+		caps[name] = value
+	}
+	return caps
+}
+
+// WantCap declares capabilities to request, now if the server already
+// offers them or as soon as it does
+func (c *Client) WantCap(names ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	toRequest := make([]string, 0)
+	for _, name := range names {
+		c.caps.wanted[name] = true
+		if _, ok := c.caps.available[name]; ok && !c.caps.isEnabled(name) {
+			toRequest = append(toRequest, name)
+		}
+	}
+
+	if len(toRequest) == 0 || c.connection() == nil || c.caps.negotiating {
+		return nil
+	}
+
+	return c.requestCaps(toRequest)
+}
+
+// Caps returns the enabled capabilities and their values
+func (c *Client) Caps() map[string]string {
+	return c.caps.enabledCaps()
+}
+
+// HasCap reports whether the capability is enabled
+func (c *Client) HasCap(name string) bool {
+	return c.caps.isEnabled(name)
+}
+
+// send CAP LS 302, starting the negotiation
+func (c *Client) SendCAPLS() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.caps.negotiating = true
+	if err := c.SendMsg(irc.NewMsg("CAP", "LS", "302")); err != nil {
+		return fmt.Errorf("error sending cap ls: %w", err)
+	}
+	return nil
+}
+
+// send CAP END
+func (c *Client) SendCAPEND() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sendCAPEND()
+}
+
+func (c *Client) sendCAPEND() error {
+	c.caps.negotiating = false
+	if err := c.SendMsg(irc.NewMsg("CAP", "END")); err != nil {
+		return fmt.Errorf("error sending cap end: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) requestCaps(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	slices.Sort(names)
+
+	c.caps.pending++
+	if err := c.SendMsg(irc.NewMsg("CAP", "REQ").WithTrailing(strings.Join(names, " "))); err != nil {
+		return fmt.Errorf("error sending cap req: %w", err)
+	}
+	return nil
+}
+
+// wantedAvailable lists the wanted capabilities offered in caps and not
+// enabled yet
+func (c *Client) wantedAvailable(caps map[string]string) []string {
+	names := make([]string, 0)
+	for name := range caps {
+		if c.caps.wanted[name] && !c.caps.isEnabled(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// end the negotiation once nothing is pending anymore
+func (c *Client) maybeEndCaps() error {
+	if !c.caps.negotiating || c.caps.ls != nil || c.caps.pending > 0 || c.sasl != nil {
+		return nil
+	}
+	return c.sendCAPEND()
+}
+
+// Handle CAP
+func (c *Client) HandleCAP(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(msg.Args) < 2 {
+		return fmt.Errorf("invalid cap message: %s", msg.Raw)
+	}
+
+	subcommand := strings.ToUpper(msg.Args[1])
+	params := msg.Args[2:]
+
+	// a "*" before the list means more lines are coming
+	more := len(params) > 1 && params[0] == "*"
+	list := ""
+	if len(params) > 0 {
+		list = params[len(params)-1]
+	}
+
+	switch subcommand {
+	case "LS":
+		if c.caps.ls == nil {
+			c.caps.ls = make(map[string]string)
+		}
+		maps.Copy(c.caps.ls, parseCapList(list))
+		if more {
+			return nil
+		}
+
+		c.caps.available = c.caps.ls
+		c.caps.ls = nil
+		c.logger.Debug("server capabilities", "caps", c.caps.available)
+
+		if err := c.requestCaps(c.wantedAvailable(c.caps.available)); err != nil {
+			return err
+		}
+
+		if _, ok := c.caps.available["sasl"]; c.cfg.SASL.Mechanism != "" && !ok {
+			return c.saslDone(ErrSASLUnavailable)
+		}
+		return c.maybeEndCaps()
+
+	case "ACK":
+		acked := parseCapList(list)
+		for name := range acked {
+			if removed, ok := strings.CutPrefix(name, "-"); ok {
+				c.caps.disable(removed)
+				continue
+			}
+			c.caps.enable(name, c.caps.available[name])
+		}
+		c.caps.pending = max(c.caps.pending-1, 0)
+		c.logger.Info("capabilities enabled", "caps", c.caps.enabledCaps())
+
+		if _, ok := acked["sasl"]; ok && c.cfg.SASL.Mechanism != "" && c.caps.negotiating {
+			if err := c.startSASL(); err != nil {
+				return c.saslDone(err)
+			}
+		}
+		return c.maybeEndCaps()
+
+	case "NAK":
+		c.caps.pending = max(c.caps.pending-1, 0)
+		c.logger.Warn("capabilities rejected", "caps", list)
+
+		if _, ok := parseCapList(list)["sasl"]; ok && c.cfg.SASL.Mechanism != "" {
+			return c.saslDone(ErrSASLUnavailable)
+		}
+		return c.maybeEndCaps()
+
+	case "NEW":
+		caps := parseCapList(list)
+		maps.Copy(c.caps.available, caps)
+		return c.requestCaps(c.wantedAvailable(caps))
+
+	case "DEL":
+		for name := range parseCapList(list) {
+			delete(c.caps.available, name)
+			c.caps.disable(name)
+		}
+		c.logger.Info("capabilities removed", "caps", list)
+		return nil
+
+	case "LIST":
+		return nil
+	}
+
+	return fmt.Errorf("unknown cap subcommand: %s", subcommand)
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapNegotiation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Caps = []string{"message-tags", "server-time", "multi-prefix", "unsupported"}
+	client, conn := newTestClient(t, cfg)
+
+	assert.NoError(t, client.SendCAPLS())
+	assert.Equal(t, []string{"CAP LS 302"}, conn.lines())
+
+	receive(t, client,
+		":irc.test CAP * LS * :multi-prefix sasl=PLAIN,EXTERNAL",
+		":irc.test CAP * LS :message-tags server-time",
+	)
+	assert.Equal(t, []string{"CAP REQ :message-tags multi-prefix server-time"}, conn.lines())
+	assert.Equal(t, "PLAIN,EXTERNAL", client.caps.available["sasl"])
+
+	receive(t, client, ":irc.test CAP * ACK :message-tags multi-prefix server-time")
+	assert.Equal(t, []string{"CAP END"}, conn.lines())
+	assert.True(t, client.HasCap("message-tags"))
+	assert.False(t, client.HasCap("sasl"))
+
+	// runtime changes
+	receive(t, client, ":irc.test CAP gral NEW :unsupported away-notify")
+	assert.Equal(t, []string{"CAP REQ :unsupported"}, conn.lines())
+
+	receive(t, client, ":irc.test CAP gral NAK :unsupported")
+	assert.Empty(t, conn.lines())
+	assert.False(t, client.HasCap("unsupported"))
+
+	receive(t, client, ":irc.test CAP gral DEL :server-time")
+	assert.False(t, client.HasCap("server-time"))
+	assert.Equal(t, map[string]string{"message-tags": "", "multi-prefix": ""}, client.Caps())
+
+	assert.NoError(t, client.WantCap("away-notify"))
+	assert.Equal(t, []string{"CAP REQ :away-notify"}, conn.lines())
+}
+
+func TestCapNothingToRequest(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Caps = []string{"server-time"}
+	client, conn := newTestClient(t, cfg)
+
+	assert.NoError(t, client.SendCAPLS())
+	receive(t, client, ":irc.test CAP * LS :sasl")
+	assert.Equal(t, []string{"CAP LS 302", "CAP END"}, conn.lines())
+}
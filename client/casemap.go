@@ -0,0 +1,40 @@
+package client
+
+import (
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// refold rekeys a map after the casemapping changed
+func refold[V any](in map[string]V, m irc.CaseMapping, name func(V) string) map[string]V {
+	out := make(map[string]V, len(in))
+	for key, v := range in {
+		if name != nil {
+			key = name(v)
+		}
+		out[m.Fold(key)] = v
+	}
+	return out
+}
+
+// fold folds a nick or channel name with the server casemapping, the
+// client lock must be held
+func (c *Client) fold(s string) string {
+	return c.info.CaseMapping.Fold(s)
+}
+
+// CaseMapping returns the casemapping used by the server
+func (c *Client) CaseMapping() irc.CaseMapping {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.info.CaseMapping
+}
+
+// refoldState rekeys the state after the casemapping changed, the client
+// lock must be held
+func (c *Client) refoldState() {
+	m := c.info.CaseMapping
+	c.channels = refold(c.channels, m, func(ch *Channel) string { return ch.name })
+	c.queries = refold(c.queries, m, func(q *Query) string { return q.Nick })
+	c.keys = refold(c.keys, m, nil)
+	c.logger.Debug("casemapping", "casemapping", m)
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+func TestClientCaseMapping(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	client, conn := newTestClient(t, cfg)
+
+	receive(t, client,
+		":gral!gral@host JOIN #Gral.IRC",
+		":irc.test 332 gral #gral.irc :the topic",
+		":Bob[away]!b@host JOIN #GRAL.irc",
+		":irc.test 005 gral CHANTYPES=# CASEMAPPING=ascii :are supported by this server",
+	)
+	assert.Equal(t, irc.CaseMappingASCII, client.CaseMapping())
+
+	channels := client.Channels()
+	require.Len(t, channels, 1)
+	assert.Equal(t, "#Gral.IRC", channels[0].Name)
+	assert.Equal(t, "the topic", channels[0].Topic)
+	assert.Len(t, channels[0].Users, 2)
+
+	// ascii doesn't fold [] anymore
+	receive(t, client, ":bob{away}!b@host PART #gral.irc")
+	ch, ok := client.Channel("#GRAL.IRC")
+	require.True(t, ok)
+	assert.Len(t, ch.Users, 2)
+
+	receive(t, client, ":BOB[AWAY]!b@host NICK bob")
+	receive(t, client, ":Bob!b@host PART #gral.irc")
+	ch, _ = client.Channel("#gral.irc")
+	assert.Equal(t, []Member{{UserIdentity: irc.UserIdentity{Nick: "gral", User: "gral", Host: "host"}}}, ch.Users)
+
+	conn.lines()
+	receive(t, client, ":alice!a@host PRIVMSG #GRAL.irc :!topic")
+	assert.Equal(t, []string{"PRIVMSG #GRAL.irc :Topic: the topic"}, conn.lines())
+
+	receive(t, client, ":alice!a@host PRIVMSG GRAL :help")
+	assert.Len(t, conn.lines(), 1)
+	_, ok = client.Query("ALICE")
+	assert.True(t, ok)
+
+	receive(t, client, ":GRAL!gral@host PART #gral.irc")
+	assert.Empty(t, client.Channels())
+}
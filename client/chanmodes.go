@@ -0,0 +1,162 @@
+package client
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// ChannelModes is the mode set of a channel
+type ChannelModes struct {
+	// Flags are the set modes without a parameter such as "nt"
+	Flags string
+	// Params holds the set modes with a parameter such as k and l
+	Params map[byte]string
+	// Lists holds the masks of list modes such as b, e and I
+	Lists map[byte][]string
+}
+
+// NewChannelModes returns an empty mode set
+func NewChannelModes() ChannelModes {
+	return ChannelModes{
+		Params: make(map[byte]string),
+		Lists:  make(map[byte][]string),
+	}
+}
+
+func (m ChannelModes) clone() ChannelModes {
+	lists := make(map[byte][]string, len(m.Lists))
+	for mode, masks := range m.Lists {
+		lists[mode] = slices.Clone(masks)
+	}
+	m.Params = maps.Clone(m.Params)
+	m.Lists = lists
+	return m
+}
+
+// Has reports whether a flag or parameter mode is set
+func (m ChannelModes) Has(mode byte) bool {
+	_, ok := m.Params[mode]
+	return ok || strings.IndexByte(m.Flags, mode) >= 0
+}
+
+// Key returns the channel key, empty when unset
+func (m ChannelModes) Key() string {
+	return m.Params['k']
+}
+
+// Limit returns the user limit, 0 when unset
+func (m ChannelModes) Limit() int {
+	return atoi(m.Params['l'])
+}
+
+// Bans returns the ban masks
+func (m ChannelModes) Bans() []string {
+	return m.Lists['b']
+}
+
+// Excepts returns the ban exception masks
+func (m ChannelModes) Excepts() []string {
+	return m.Lists['e']
+}
+
+// Invites returns the invite exception masks
+func (m ChannelModes) Invites() []string {
+	return m.Lists['I']
+}
+
+// String formats the flag and parameter modes as in a MODE message, such
+// as "+ntkl secret 10"
+func (m ChannelModes) String() string {
+	modes := make([]byte, 0, len(m.Params))
+	for mode := range m.Params {
+		modes = append(modes, mode)
+	}
+	sort.Slice(modes, func(i, j int) bool { return modes[i] < modes[j] })
+
+	params := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		params = append(params, m.Params[mode])
+	}
+
+	return strings.Join(append([]string{"+" + m.Flags + string(modes)}, params...), " ")
+}
+
+// Apply applies the changes of a MODE message, the prefix modes of members
+// are left to the channel
+func (m *ChannelModes) Apply(info ServerInfo, casemap irc.CaseMapping, changes []ModeChange) {
+	for _, change := range changes {
+		mode := change.Mode
+		switch {
+		case strings.IndexByte(info.PrefixModes, mode) >= 0:
+			continue
+		case strings.IndexByte(info.ChanModes.List, mode) >= 0:
+			if change.Param == "" {
+				// a list query, not a change
+				continue
+			}
+			masks := slices.DeleteFunc(m.Lists[mode], func(mask string) bool {
+				return casemap.Equal(mask, change.Param)
+			})
+			if change.Add {
+				masks = append(masks, change.Param)
+			}
+			if len(masks) == 0 {
+				delete(m.Lists, mode)
+			} else {
+				m.Lists[mode] = masks
+			}
+		case strings.IndexByte(info.ChanModes.Param, mode) >= 0,
+			strings.IndexByte(info.ChanModes.SetParam, mode) >= 0:
+			if change.Add {
+				m.Params[mode] = change.Param
+			} else {
+				delete(m.Params, mode)
+			}
+		default:
+			// flags, and unknown modes which we can only guess take nothing
+			m.Flags = strings.ReplaceAll(m.Flags, string(mode), "")
+			if change.Add {
+				m.Flags += string(mode)
+			}
+		}
+	}
+}
+
+// applyModes applies a MODE message to the channel modes and the prefix
+// modes of its members
+func (c *Channel) applyModes(info ServerInfo, modes string, params []string) {
+	changes := ParseModeChanges(info, modes, params)
+	for _, change := range changes {
+		if strings.IndexByte(info.PrefixModes, change.Mode) >= 0 {
+			c.setPrefixMode(change.Param, change.Mode, change.Add)
+		}
+	}
+	c.modes.Apply(info, c.casemap, changes)
+}
+
+// Handle RPL_CHANNELMODEIS
+func (c *Client) HandleRPL_CHANNELMODEIS(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// me #chan +ntkl secret 10
+	if len(msg.Args) < 3 {
+		return nil
+	}
+
+	ch, ok := c.channels[c.fold(msg.Args[1])]
+	if !ok {
+		return nil
+	}
+
+	// the reply holds every flag and parameter mode, but no list
+	ch.modes.Flags = ""
+	ch.modes.Params = make(map[byte]string)
+	ch.applyModes(c.info, msg.Args[2], msg.Args[3:])
+	c.logger.Debug("channel modes", "channel", ch.name, "modes", ch.modes.String())
+	return nil
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+func TestChannelModesApply(t *testing.T) {
+	info := DefaultServerInfo()
+	info.apply("CHANMODES", "beI,k,l,imnpst")
+
+	tests := []struct {
+		name   string
+		steps  [][]string
+		flags  string
+		params map[byte]string
+		lists  map[byte][]string
+	}{
+		{
+			name:   "key and limit keep their parameters",
+			steps:  [][]string{{"+ntkl", "secret", "10"}},
+			flags:  "nt",
+			params: map[byte]string{'k': "secret", 'l': "10"},
+			lists:  map[byte][]string{},
+		},
+		{
+			name:   "removing a flag keeps the others",
+			steps:  [][]string{{"+ntk", "secret"}, {"-t"}},
+			flags:  "n",
+			params: map[byte]string{'k': "secret"},
+			lists:  map[byte][]string{},
+		},
+		{
+			name:   "limit is unset without parameter",
+			steps:  [][]string{{"+l", "10"}, {"-l+m"}},
+			flags:  "m",
+			params: map[byte]string{},
+			lists:  map[byte][]string{},
+		},
+		{
+			name:   "list modes add and remove masks",
+			steps:  [][]string{{"+bbe", "*!*@a", "*!*@b", "*!*@c"}, {"-b+I", "*!*@A", "*!*@d"}},
+			params: map[byte]string{},
+			lists:  map[byte][]string{'b': {"*!*@b"}, 'e': {"*!*@c"}, 'I': {"*!*@d"}},
+		},
+		{
+			name:   "a list query changes nothing",
+			steps:  [][]string{{"+b"}},
+			params: map[byte]string{},
+			lists:  map[byte][]string{},
+		},
+		{
+			name:   "prefix modes are left to the members",
+			steps:  [][]string{{"+ovs", "alice", "bob"}},
+			flags:  "s",
+			params: map[byte]string{},
+			lists:  map[byte][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modes := NewChannelModes()
+			for _, step := range tt.steps {
+				modes.Apply(info, irc.CaseMappingRFC1459, ParseModeChanges(info, step[0], step[1:]))
+			}
+			assert.Equal(t, tt.flags, modes.Flags)
+			assert.Equal(t, tt.params, modes.Params)
+			assert.Equal(t, tt.lists, modes.Lists)
+		})
+	}
+}
+
+func TestChannelModesString(t *testing.T) {
+	modes := NewChannelModes()
+	assert.Equal(t, "+", modes.String())
+
+	modes.Flags = "nt"
+	modes.Params['l'] = "10"
+	modes.Params['k'] = "secret"
+	assert.Equal(t, "+ntkl secret 10", modes.String())
+	assert.Equal(t, "secret", modes.Key())
+	assert.Equal(t, 10, modes.Limit())
+	assert.True(t, modes.Has('n'))
+	assert.True(t, modes.Has('k'))
+	assert.False(t, modes.Has('m'))
+}
+
+func TestHandleChannelModes(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	client, conn := newTestClient(t, cfg)
+
+	receive(t, client, ":gral!gral@host JOIN #chan")
+	assert.Equal(t, []string{"MODE #chan"}, conn.lines())
+
+	receive(t, client,
+		":irc.test 324 gral #chan +ntkl secret 10",
+		":op!o@host MODE #chan +b-t *!*@spam",
+		":op!o@host MODE #chan -k+m secret",
+	)
+
+	ch, ok := client.Channel("#chan")
+	require.True(t, ok)
+	assert.Equal(t, "+nml 10", ch.Modes.String())
+	assert.Equal(t, []string{"*!*@spam"}, ch.Modes.Bans())
+	assert.Equal(t, "", ch.Modes.Key())
+
+	// the reply replaces the flags but keeps the lists
+	receive(t, client, ":irc.test 324 gral #chan +s")
+	ch, _ = client.Channel("#chan")
+	assert.Equal(t, "+s", ch.Modes.String())
+	assert.Equal(t, []string{"*!*@spam"}, ch.Modes.Bans())
+}
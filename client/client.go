@@ -0,0 +1,1058 @@
+// Package client is an IRC client keeping the state of the connection,
+// with bot commands
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+var (
+	ErrUnknwonCommand = errors.New("unknown command")
+	ErrNotConnected   = errors.New("not connected")
+)
+
+// Handler handles a command, numeric or client event
+type Handler func(irc.Msg) error
+
+type Channel struct {
+	name            string
+	modes           ChannelModes
+	Messages        []string
+	Topic           string
+	TopicChangeTime time.Time
+	TopicChangeBy   string
+	Users           []*Member
+
+	// casemap and prefixModes come from the server info, to find members
+	// and order their modes
+	casemap     irc.CaseMapping
+	prefixModes string
+
+	shouldResetNames bool
+}
+
+func NewChannel(name string) *Channel {
+	info := DefaultServerInfo()
+	return &Channel{
+		name:        name,
+		modes:       NewChannelModes(),
+		Messages:    make([]string, 0),
+		casemap:     info.CaseMapping,
+		prefixModes: info.PrefixModes,
+	}
+}
+
+// newChannel creates a channel following the server info, the client lock
+// must be held
+func (c *Client) newChannel(name string) *Channel {
+	ch := NewChannel(name)
+	ch.casemap = c.info.CaseMapping
+	ch.prefixModes = c.info.PrefixModes
+	return ch
+}
+
+func (c *Channel) AddMessage(msg string) {
+	c.Messages = append(c.Messages, msg)
+}
+
+// removeUser removes nick from the users, it reports whether it was there
+func (c *Channel) removeUser(nick string) bool {
+	for i, u := range c.Users {
+		if c.casemap.Equal(u.Nick, nick) {
+			c.Users = slices.Delete(c.Users, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+type Client struct {
+	// connMu guards conn and queue, it is never taken before mu
+	connMu sync.RWMutex
+	conn   net.Conn
+	// queue throttles the lines sent, nil when flood protection is disabled
+	queue *SendQueue
+
+	logger *slog.Logger
+	events *Dispatcher
+	cfg    Config
+	// dial opens the connections of Run
+	dial DialFunc
+
+	// stateMu guards the connection state, it is never held while taking
+	// the other locks
+	stateMu sync.Mutex
+	state   ConnState
+	// registered is closed once the server welcomed us
+	registered chan struct{}
+
+	// pingMu guards the keepalive state, it is never held while taking
+	// the other locks
+	pingMu sync.Mutex
+	ping   pingState
+
+	// mu guards the state below, updated by the handlers in the reader
+	// goroutine and read by the accessors from any goroutine
+	mu sync.RWMutex
+
+	motd []string
+
+	me irc.UserIdentity
+	// nickAttempt counts the nicks refused during registration
+	nickAttempt int
+	// regainTimer retries the primary nick while we use another one
+	regainTimer *time.Timer
+
+	// userModes are our user modes such as "iB"
+	userModes string
+	// info holds the server features, its casemapping folds the keys of
+	// channels, queries and keys
+	info     ServerInfo
+	channels map[string]*Channel
+	// private conversations by folded nick
+	queries map[string]*Query
+
+	// channel keys given to JoinKey, reused when rejoining
+	keys map[string]string
+	// channels to rejoin after a reconnection
+	rejoin []ChannelConfig
+
+	caps *capState
+	sasl *saslSession
+	// account we are logged in as with SASL
+	account string
+	// fatal is set when the connection failed in a way reconnecting won't fix
+	fatal error
+
+	router *CommandRouter
+}
+
+func (c *Client) setupHandlers() {
+	c.events = NewDispatcher()
+
+	for event, h := range map[string]Handler{
+		"RPL_WELCOME":          c.HandleRPL_WELCOME,
+		"RPL_YOURHOST":         c.HandleRPL_YOURHOST,
+		"RPL_CREATED":          c.HandleRPL_CREATED,
+		"RPL_MYINFO":           c.HandleRPL_MYINFO,
+		"PING":                 c.HandlePing,
+		"PONG":                 c.HandlePONG,
+		"ERROR":                c.HandleERROR,
+		"RPL_MOTD":             c.HandleRPL_MOTD,
+		"RPL_ENDOFMOTD":        c.HandleRPL_ENDOFMOTD,
+		"ERR_NOMOTD":           c.HandleRPL_ENDOFMOTD,
+		"RPL_UMODEIS":          c.HandleRPL_UMODEIS,
+		"RPL_MOTDSTART":        c.HandleRPL_MOTDSTART,
+		"JOIN":                 c.HandleJOIN,
+		"RPL_NAMREPLY":         c.HandleRPL_NAMREPLY,
+		"RPL_ENDOFNAMES":       c.HandleRPL_ENDOFNAMES,
+		"PRIVMSG":              c.HandlePRIVMSG,
+		"RPL_TOPIC":            c.HandleRPL_TOPIC,
+		"PART":                 c.HandlePART,
+		"QUIT":                 c.HandleQUIT,
+		"NICK":                 c.HandleNICK,
+		"MODE":                 c.HandleMODE,
+		"KICK":                 c.HandleKICK,
+		"TOPIC":                c.HandleTOPIC,
+		"RPL_NOTOPIC":          c.HandleRPL_NOTOPIC,
+		"RPL_TOPICWHOTIME":     c.HandleRPL_TOPICWHOTIME,
+		"CAP":                  c.HandleCAP,
+		"AUTHENTICATE":         c.HandleAUTHENTICATE,
+		"RPL_LOGGEDIN":         c.HandleRPL_LOGGEDIN,
+		"RPL_LOGGEDOUT":        c.HandleRPL_LOGGEDOUT,
+		"RPL_SASLSUCCESS":      c.HandleRPL_SASLSUCCESS,
+		"ERR_SASLFAIL":         c.HandleSASLFailure,
+		"ERR_SASLTOOLONG":      c.HandleSASLFailure,
+		"ERR_SASLABORTED":      c.HandleSASLFailure,
+		"ERR_SASLALREADY":      c.HandleSASLFailure,
+		"ERR_NICKLOCKED":       c.HandleSASLFailure,
+		"RPL_SASLMECHS":        c.HandleRPL_SASLMECHS,
+		"RPL_ISUPPORT":         c.HandleRPL_ISUPPORT,
+		"RPL_CHANNELMODEIS":    c.HandleRPL_CHANNELMODEIS,
+		"ERR_ERRONEUSNICKNAME": c.HandleNickError,
+		"ERR_NICKNAMEINUSE":    c.HandleNickError,
+		"ERR_NICKCOLLISION":    c.HandleNickError,
+		"ERR_UNAVAILRESOURCE":  c.HandleNickError,
+	} {
+		c.events.Subscribe(event, PriorityDefault, h)
+	}
+}
+
+// On adds a handler for a command, numeric or client event, EventAll for
+// all of them
+func (c *Client) On(event string, h Handler) Subscription {
+	return c.events.Subscribe(event, PriorityDefault, h)
+}
+
+// OnPriority adds a handler running before the handlers of lower priority,
+// the built-in ones use PriorityDefault
+func (c *Client) OnPriority(event string, priority int, h Handler) Subscription {
+	return c.events.Subscribe(event, priority, h)
+}
+
+func NewClient(logger *slog.Logger, cfg Config) *Client {
+	c := &Client{
+		logger: logger, channels: make(map[string]*Channel),
+		queries: make(map[string]*Query),
+		cfg:     cfg,
+		keys:    make(map[string]string),
+		caps:    newCapState(cfg.Caps),
+		info:    DefaultServerInfo(),
+
+		registered: make(chan struct{}),
+	}
+	c.dial = func(ctx context.Context) (net.Conn, error) {
+		return Dial(ctx, cfg.Server)
+	}
+	c.setupHandlers()
+
+	if cfg.SASL.Mechanism != "" {
+		c.caps.wanted["sasl"] = true
+	}
+
+	c.router = NewCommandRouter(c, cfg.CommandPrefix)
+	c.registerBuiltinCommands()
+
+	c.motd = make([]string, 0)
+
+	return c
+}
+
+// attach starts using conn for a new connection
+func (c *Client) attach(conn net.Conn) {
+	c.connMu.Lock()
+	c.conn = conn
+	if c.cfg.Flood.Enabled() {
+		c.queue = NewSendQueue(c.cfg.Flood, conn.Write, func(err error) {
+			c.logger.Error("error sending queued line", "error", err)
+		})
+	}
+	c.connMu.Unlock()
+
+	c.setState(StateConnecting)
+
+	c.pingMu.Lock()
+	c.ping = pingState{lastRead: time.Now()}
+	c.pingMu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.me = irc.UserIdentity{Nick: c.cfg.Nick}
+	c.nickAttempt = 0
+	c.userModes = ""
+	c.info = DefaultServerInfo()
+	c.caps.reset()
+	c.sasl = nil
+	c.account = ""
+	c.fatal = nil
+}
+
+// fatalError returns the error that should stop the supervisor, if any
+func (c *Client) fatalError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.fatal
+}
+
+// detach forgets the state of the lost connection and remembers the
+// joined channels so they are rejoined after the next registration
+func (c *Client) detach() {
+	c.connMu.Lock()
+	queue := c.queue
+	c.conn = nil
+	c.queue = nil
+	c.connMu.Unlock()
+
+	if queue != nil {
+		queue.Close()
+	}
+
+	c.setState(StateDisconnected)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stopRegain()
+
+	for key, channel := range c.channels {
+		// the key may have changed since we joined, some servers hide it
+		// behind a *
+		chanKey := c.keys[key]
+		if k := channel.modes.Key(); k != "" && k != "*" {
+			chanKey = k
+		}
+		c.rejoin = append(c.rejoin, ChannelConfig{Name: channel.name, Key: chanKey})
+	}
+
+	c.channels = make(map[string]*Channel)
+	c.queries = make(map[string]*Query)
+	c.motd = make([]string, 0)
+}
+
+// connection returns the current connection, nil when disconnected
+func (c *Client) connection() net.Conn {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.conn
+}
+
+func (c *Client) Write(data []byte) (int, error) {
+	conn := c.connection()
+	if conn == nil {
+		return 0, ErrNotConnected
+	}
+	return conn.Write(data)
+}
+
+func (c *Client) Close() error {
+	conn := c.connection()
+	if conn == nil {
+		return ErrNotConnected
+	}
+	return conn.Close()
+}
+
+// send data to server, through the flood protection queue when enabled
+func (c *Client) Send(data []byte) (int, error) {
+	c.logger.Debug("->", "data", string(data))
+	data = append(data, '\r', '\n')
+
+	c.connMu.RLock()
+	queue := c.queue
+	c.connMu.RUnlock()
+
+	if queue == nil {
+		return c.Write(data)
+	}
+	if err := queue.Push(data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// send data to server now, ahead of the queued lines
+func (c *Client) SendNow(data []byte) (int, error) {
+	c.logger.Debug("->", "data", string(data))
+	data = append(data, '\r', '\n')
+	return c.Write(data)
+}
+
+// Drain waits until the queued lines are sent
+func (c *Client) Drain(ctx context.Context) error {
+	c.connMu.RLock()
+	queue := c.queue
+	c.connMu.RUnlock()
+
+	if queue == nil {
+		return nil
+	}
+	return queue.Drain(ctx)
+}
+
+// SendMsg encodes and sends a message
+func (c *Client) SendMsg(msg irc.Msg) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+
+	tags, err := c.outgoingTags(msg.Tags)
+	if err != nil {
+		return err
+	}
+	msg.Tags = tags
+
+	// PING, PONG and QUIT skip the queue, a late PONG gets us disconnected
+	// and a queued PING would skew the lag
+	if msg.Command == "PING" || msg.Command == "PONG" || msg.Command == "QUIT" {
+		_, err = c.SendNow(msg.Bytes())
+		return err
+	}
+
+	_, err = c.Send(msg.Bytes())
+	return err
+}
+
+func (c *Client) Read(data []byte) (int, error) {
+	conn := c.connection()
+	if conn == nil {
+		return 0, ErrNotConnected
+	}
+	return conn.Read(data)
+}
+
+// ReadLoop reads and handles messages until reading from the server fails
+func (c *Client) ReadLoop() error {
+	conn := c.connection()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	reader := irc.NewLineReader(conn, irc.DefaultMaxLineLen)
+	for {
+		line, err := reader.ReadLine()
+		if errors.Is(err, irc.ErrLineTooLong) {
+			c.logger.Error("dropping message", "error", err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error reading from server: %w", err)
+		}
+		c.touch()
+
+		c.logger.Debug(line)
+
+		parse := irc.ParseMessage
+		if c.cfg.StrictParsing {
+			parse = irc.ParseMessageStrict
+		}
+
+		m, err := parse(line)
+		if err != nil {
+			c.logger.Error("dropping malformed message", "error", err, "line", line)
+			continue
+		}
+
+		if err = c.Handle(*m); err != nil {
+			c.logger.Error("error handling message", "error", err, "message", m)
+			continue
+		}
+	}
+}
+
+// emit dispatches a client event to its handler, if any
+func (c *Client) emit(event string) {
+	err := c.Handle(irc.Msg{Command: event})
+	if err != nil && !errors.Is(err, ErrUnknwonCommand) {
+		c.logger.Error("error handling event", "event", event, "error", err)
+	}
+}
+
+// Handle RPL_MOTD
+func (c *Client) HandleRPL_MOTD(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.motd = append(c.motd, strings.Join(msg.Args[0:], " "))
+	return nil
+}
+
+// Handle RPL_ENDOFMOTD
+func (c *Client) HandleRPL_ENDOFMOTD(msg irc.Msg) error {
+	// join the configured channels and the ones we were in before a reconnection
+	c.mu.Lock()
+	channels := slices.Concat(c.cfg.Channels, c.rejoin)
+	c.rejoin = nil
+	casemap := c.info.CaseMapping
+	c.mu.Unlock()
+
+	joined := make(map[string]bool)
+	for _, ch := range channels {
+		if joined[casemap.Fold(ch.Name)] {
+			continue
+		}
+		joined[casemap.Fold(ch.Name)] = true
+
+		if err := c.JoinKey(ch.Name, ch.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handle RPL_UMODEIS
+func (c *Client) HandleRPL_UMODEIS(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// me +iw
+	if len(msg.Args) < 2 {
+		return nil
+	}
+	c.userModes = applyUserModes("", msg.Args[1])
+	c.me.Nick = msg.Args[0]
+
+	return nil
+}
+
+func (c *Client) HandleRPL_MOTDSTART(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.motd = make([]string, 0)
+	return nil
+}
+
+func (c *Client) HandleRPL_WELCOME(msg irc.Msg) error {
+	c.logger.Info("WELCOME")
+
+	c.mu.Lock()
+
+	// servers without CAP support register us without CAP END
+	c.caps.negotiating = false
+
+	// the first arg is our nick as the server confirms it, the welcome
+	// text usually ends with our nick!user@host
+	if len(msg.Args) > 1 {
+		c.me = irc.UserIdentity{Nick: msg.Args[0]}
+	}
+	if fields := strings.Fields(msg.Trailing); len(fields) > 0 {
+		if me, err := irc.ParseUserIdentity(fields[len(fields)-1]); err == nil && c.info.CaseMapping.Equal(me.Nick, c.me.Nick) {
+			c.me = me
+		}
+	}
+	c.setState(StateRegistered)
+
+	if !c.info.CaseMapping.Equal(c.me.Nick, c.cfg.Nick) {
+		c.scheduleRegain()
+	}
+
+	if c.cfg.UserModes != "" && len(msg.Args) > 0 {
+		if err := c.SendMODE(msg.Args[0], c.cfg.UserModes); err != nil {
+			c.logger.Error("error setting user modes", "modes", c.cfg.UserModes, "error", err)
+		}
+	}
+	c.mu.Unlock()
+
+	c.emit(EventRegistered)
+	return nil
+}
+
+// send PRIVMSG
+func (c *Client) SendPRIVMSG(target, message string) error {
+	if err := c.sendText("PRIVMSG", nil, target, message); err != nil {
+		return fmt.Errorf("error sending message: %w", err)
+	}
+
+	return nil
+}
+
+// send NOTICE
+func (c *Client) SendNOTICE(target, message string) error {
+	if err := c.sendText("NOTICE", nil, target, message); err != nil {
+		return fmt.Errorf("error sending notice: %w", err)
+	}
+
+	return nil
+}
+
+// Router returns the bot command router, to register commands
+func (c *Client) Router() *CommandRouter {
+	return c.router
+}
+
+func (c *Client) HandlePRIVMSG(msg irc.Msg) error {
+	target := msg.Target
+
+	// commands run without the lock, with snapshots of the state
+	c.mu.Lock()
+
+	// a message to "@#chan" reaches the channel operators only
+	_, channelName := c.info.SplitStatusMsg(target)
+
+	if !c.info.IsChannel(channelName) {
+		// Private message
+		if !c.info.CaseMapping.Equal(target, c.me.Nick) || msg.Nick == "" {
+			c.mu.Unlock()
+			return nil
+		}
+
+		query := c.query(msg.Nick)
+		query.AddMessage(msg.Raw)
+		snapshot := query.snapshot()
+		c.mu.Unlock()
+
+		c.logger.Info("private message", "message", msg.Trailing, "from", msg.Nick)
+
+		return c.router.Handle(msg, msg.Nick, nil, &snapshot)
+	} else {
+		if channel, ok := c.channels[c.fold(channelName)]; ok {
+			channel.AddMessage(msg.Raw)
+			snapshot := channel.Snapshot()
+			c.mu.Unlock()
+
+			message := strings.Join(msg.Args[1:], " ")
+
+			c.logger.Info("message", "channel", target, "message", message, "from", msg.Nick)
+
+			return c.router.Handle(msg, target, &snapshot, nil)
+		} else {
+			c.mu.Unlock()
+			c.logger.Error("channel not found", "channel", target)
+		}
+		return nil
+	}
+}
+
+func (c *Client) Handle(msg irc.Msg) error {
+	if err := c.events.Dispatch(msg.CommandName(), msg); err != nil {
+		return fmt.Errorf(
+			"error handling message command:%s|%s: %w",
+			msg.Command,
+			msg.CommandName(),
+			err,
+		)
+	}
+
+	return nil
+}
+
+func (c *Client) HandlePing(msg irc.Msg) error {
+	// PING :token
+	if len(msg.Args) == 0 {
+		return nil
+	}
+	if err := c.SendMsg(irc.NewMsg("PONG", msg.Args[0])); err != nil {
+		return fmt.Errorf("error sending pong: %w", err)
+	}
+
+	return nil
+}
+
+// topics
+
+func (c *Client) HandleRPL_TOPIC(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// me #chan :topic
+	if len(msg.Args) < 2 {
+		return nil
+	}
+	channel := msg.Args[1]
+	ch, ok := c.channels[c.fold(channel)]
+	if !ok {
+		// a TOPIC query for a channel we are not in
+		return nil
+	}
+	ch.Topic = msg.Trailing
+	ch.TopicChangeTime = time.Now()
+
+	return nil
+}
+
+// handle TOPIC, someone changed the topic of a channel we are in
+func (c *Client) HandleTOPIC(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	channel := msg.Target
+	ch, ok := c.channels[c.fold(channel)]
+	if !ok {
+		c.logger.Error("channel not found", "channel", channel)
+		return nil
+	}
+	ch.Topic = msg.Trailing
+	ch.TopicChangeTime = time.Now()
+	ch.TopicChangeBy = msg.Nick
+
+	return nil
+}
+
+func (c *Client) HandleRPL_NOTOPIC(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// me #chan :No topic is set
+	if len(msg.Args) < 2 {
+		return nil
+	}
+	channel := msg.Args[1]
+	ch, ok := c.channels[c.fold(channel)]
+	if !ok {
+		return nil
+	}
+	ch.Topic = ""
+	ch.TopicChangeTime = time.Now()
+
+	return nil
+}
+
+// handle 333 RPL_TOPICWHOTIME
+func (c *Client) HandleRPL_TOPICWHOTIME(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// me #chan nick!user@host 1700000000
+	if len(msg.Args) < 4 {
+		return nil
+	}
+	channel := msg.Args[1]
+	ch, ok := c.channels[c.fold(channel)]
+	if !ok {
+		c.logger.Error("channel not found", "channel", channel)
+		return nil
+	}
+
+	unixTimestamp := msg.Args[3]
+	intUnixTimestamp, err := strconv.ParseInt(unixTimestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("error parsing unix timestamp: %w", err)
+	}
+	t := time.Unix(intUnixTimestamp, 0)
+	ch.TopicChangeTime = t.In(time.FixedZone("UTC", 0))
+
+	ch.TopicChangeBy = msg.Args[2]
+
+	return nil
+}
+
+// JOIN
+func (c *Client) Join(channel string) error {
+	if err := c.SendMsg(irc.NewMsg("JOIN", channel)); err != nil {
+		return fmt.Errorf("error sending join: %w", err)
+	}
+	return nil
+}
+
+// JOIN with a channel key
+func (c *Client) JoinKey(channel, key string) error {
+	c.mu.Lock()
+	c.keys[c.fold(channel)] = key
+	c.mu.Unlock()
+
+	if key == "" {
+		return c.Join(channel)
+	}
+	if err := c.SendMsg(irc.NewMsg("JOIN", channel, key)); err != nil {
+		return fmt.Errorf("error sending join: %w", err)
+	}
+	return nil
+}
+
+// Handle JOIN
+func (c *Client) HandleJOIN(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user := irc.UserIdentity{Nick: msg.Nick, User: msg.User, Host: msg.Host}
+
+	self := c.info.CaseMapping.Equal(user.Nick, c.me.Nick)
+
+	channel := msg.Target
+	ch, ok := c.channels[c.fold(channel)]
+	if !ok {
+		if !self {
+			c.logger.Error("channel not found", "channel", channel)
+			return nil
+		}
+		ch = c.newChannel(channel)
+		c.channels[c.fold(channel)] = ch
+	}
+
+	ch.Users = append(ch.Users, &Member{UserIdentity: user})
+
+	if self {
+		c.logger.Info("joined channel", "channel", channel)
+		// our prefix as seen by others, it limits the length of messages
+		c.me = user
+
+		// the JOIN doesn't tell the modes, RPL_CHANNELMODEIS will
+		if err := c.SendMODE(channel, ""); err != nil {
+			c.logger.Error("error asking channel modes", "channel", channel, "error", err)
+		}
+	}
+
+	ch.shouldResetNames = true
+
+	return nil
+}
+
+// handle RPL_NAMEREPLY
+func (c *Client) HandleRPL_NAMREPLY(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// me = #chan :@op +voice user
+	if len(msg.Args) < 3 {
+		return nil
+	}
+	channel := msg.Args[2]
+	ch, ok := c.channels[c.fold(channel)]
+	if !ok {
+		// a NAMES query for a channel we are not in
+		return nil
+	}
+
+	if ch.shouldResetNames {
+		ch.Users = make([]*Member, 0)
+		ch.shouldResetNames = false
+	}
+
+	users := strings.Fields(msg.Trailing)
+
+	for _, user := range users {
+		symbols, nick := c.info.SplitPrefix(user)
+
+		modes := make([]byte, 0, len(symbols))
+		for i := 0; i < len(symbols); i++ {
+			if mode, ok := c.info.PrefixMode(symbols[i]); ok {
+				modes = append(modes, mode)
+			}
+		}
+
+		ch.Users = append(ch.Users, &Member{
+			UserIdentity: irc.UserIdentity{Nick: nick},
+			Modes:        sortPrefixModes(c.info.PrefixModes, string(modes)),
+		})
+	}
+	return nil
+}
+
+// handle RPL_ENDOFNAMES
+func (c *Client) HandleRPL_ENDOFNAMES(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// me #chan :End of /NAMES list.
+	if len(msg.Args) < 2 {
+		return nil
+	}
+	channel := msg.Args[1]
+	ch, ok := c.channels[c.fold(channel)]
+	if !ok {
+		return nil
+	}
+
+	ch.shouldResetNames = true
+	return nil
+}
+
+// handle PART
+func (c *Client) HandlePART(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	channel := msg.Target
+	ch, ok := c.channels[c.fold(channel)]
+	if !ok {
+		c.logger.Error("channel not found", "channel", channel)
+		return nil
+	}
+
+	user := irc.UserIdentity{Nick: msg.Nick, User: msg.User, Host: msg.Host}
+
+	ch.removeUser(user.Nick)
+
+	if c.info.CaseMapping.Equal(user.Nick, c.me.Nick) {
+		c.logger.Info("left channel", "channel", channel)
+		delete(c.channels, c.fold(channel))
+	}
+
+	return nil
+}
+
+// handle QUIT
+func (c *Client) HandleQUIT(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user := irc.UserIdentity{Nick: msg.Nick, User: msg.User, Host: msg.Host}
+
+	for _, channel := range c.channels {
+		channel.removeUser(user.Nick)
+	}
+	c.closeQuery(user.Nick)
+	c.nickReleased(user.Nick)
+
+	return nil
+}
+
+// handle NICK
+func (c *Client) HandleNICK(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user := irc.UserIdentity{Nick: msg.Nick, User: msg.User, Host: msg.Host}
+	if user.Nick == "" || len(msg.Args) == 0 {
+		return fmt.Errorf("invalid nick message: %s", msg.Raw)
+	}
+
+	newNick := msg.Args[0]
+	for _, channel := range c.channels {
+		for i, u := range channel.Users {
+			if c.info.CaseMapping.Equal(u.Nick, user.Nick) {
+				channel.Users[i].Nick = newNick
+			}
+		}
+	}
+	c.renameQuery(user.Nick, newNick)
+
+	if c.info.CaseMapping.Equal(user.Nick, c.me.Nick) {
+		c.me.Nick = newNick
+		if c.info.CaseMapping.Equal(newNick, c.cfg.Nick) {
+			c.logger.Info("regained nick", "nick", newNick)
+			c.stopRegain()
+		}
+	} else {
+		c.nickReleased(user.Nick)
+	}
+
+	return nil
+}
+
+// handle MODE
+func (c *Client) HandleMODE(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.info.IsChannel(msg.Target) {
+		// only our own modes are ever sent to us
+		if c.info.CaseMapping.Equal(msg.Target, c.me.Nick) && len(msg.Args) > 1 {
+			c.userModes = applyUserModes(c.userModes, msg.Args[1])
+			c.logger.Debug("user modes", "modes", c.userModes)
+		}
+		return nil
+	} else {
+		channel := msg.Target
+		if ch, ok := c.channels[c.fold(channel)]; !ok {
+			c.logger.Error("channel not found", "channel", channel)
+		} else {
+			if len(msg.Args) > 1 {
+				ch.applyModes(c.info, msg.Args[1], msg.Args[2:])
+			}
+		}
+
+	}
+	return nil
+}
+
+// handle KICK
+func (c *Client) HandleKICK(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// #chan nick :reason
+	if len(msg.Args) < 2 {
+		return nil
+	}
+	channel := msg.Target
+	ch, ok := c.channels[c.fold(channel)]
+	if !ok {
+		c.logger.Error("channel not found", "channel", channel)
+		return nil
+	}
+
+	// user := UserIdentity{Nick: msg.Nick, User: msg.User, Host: msg.Host}
+	targettedUser := irc.UserIdentity{Nick: msg.Args[1]}
+
+	ch.removeUser(targettedUser.Nick)
+
+	if c.info.CaseMapping.Equal(targettedUser.Nick, c.me.Nick) {
+		c.logger.Info("kicked from channel", "channel", channel, "by", msg.Nick)
+		delete(c.channels, c.fold(channel))
+	}
+
+	return nil
+}
+
+// send NICK
+func (c *Client) SendNICK(nick string) error {
+	if err := c.SendMsg(irc.NewMsg("NICK", nick)); err != nil {
+		return fmt.Errorf("error sending nick: %w", err)
+	}
+	return nil
+}
+
+// send PART
+func (c *Client) SendPART(channel string) error {
+	if err := c.SendMsg(irc.NewMsg("PART", channel)); err != nil {
+		return fmt.Errorf("error sending part: %w", err)
+	}
+	return nil
+}
+
+// send QUIT
+func (c *Client) SendQUIT(reason string) error {
+	msg := irc.NewMsg("QUIT")
+	if reason != "" {
+		msg = msg.WithTrailing(reason)
+	}
+
+	if err := c.SendMsg(msg); err != nil {
+		return fmt.Errorf("error sending quit: %w", err)
+	}
+	c.setState(StateClosing)
+	return nil
+}
+
+// send KICK
+func (c *Client) SendKICK(channel, nick, reason string) error {
+	msg := irc.NewMsg("KICK", channel, nick)
+	if reason != "" {
+		msg = msg.WithTrailing(reason)
+	}
+
+	if err := c.SendMsg(msg); err != nil {
+		return fmt.Errorf("error sending kick: %w", err)
+	}
+	return nil
+}
+
+// send MODE
+func (c *Client) SendMODE(channel, mode string) error {
+	// mode may hold parameters such as "+kl secret 10"
+	params := append([]string{channel}, strings.Fields(mode)...)
+	if err := c.SendMsg(irc.NewMsg("MODE", params...)); err != nil {
+		return fmt.Errorf("error sending mode: %w", err)
+	}
+	return nil
+}
+
+// send PASS
+func (c *Client) Pass(password string) error {
+	if err := c.SendMsg(irc.NewMsg("PASS", password)); err != nil {
+		return fmt.Errorf("error sending pass: %w", err)
+	}
+	return nil
+}
+
+// send USER
+func (c *Client) User(username, realname string) error {
+	if err := c.SendMsg(irc.NewMsg("USER", username, "ignored", "ignored").WithTrailing(realname)); err != nil {
+		return fmt.Errorf("error sending user: %w", err)
+	}
+	return nil
+}
+
+// send NICK
+func (c *Client) Nick(nick string) error {
+	if err := c.SendMsg(irc.NewMsg("NICK", nick)); err != nil {
+		return fmt.Errorf("error sending nick: %w", err)
+	}
+	return nil
+}
+
+// Register starts the capability negotiation and sends PASS, NICK and
+// USER from the config
+func (c *Client) Register() error {
+	c.setState(StateNegotiating)
+
+	if err := c.SendCAPLS(); err != nil {
+		return err
+	}
+
+	if c.cfg.Server.Password != "" {
+		if err := c.Pass(c.cfg.Server.Password); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Nick(c.cfg.Nick); err != nil {
+		return err
+	}
+
+	return c.User(c.cfg.User, c.cfg.RealName)
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// recordConn is a net.Conn recording what the client sends
+type recordConn struct {
+	net.Conn
+	mu   sync.Mutex
+	sent bytes.Buffer
+}
+
+func (r *recordConn) Write(data []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sent.Write(data)
+}
+
+func (r *recordConn) Close() error {
+	return nil
+}
+
+// lines returns the lines sent since the last call
+func (r *recordConn) lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := strings.Split(strings.TrimSuffix(r.sent.String(), "\r\n"), "\r\n")
+	r.sent.Reset()
+	if len(out) == 1 && out[0] == "" {
+		return nil
+	}
+	return out
+}
+
+func newTestClient(t *testing.T, cfg Config) (*Client, *recordConn) {
+	t.Helper()
+
+	// without flood protection lines are written before Send returns
+	cfg.Flood = FloodConfig{}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := NewClient(logger, cfg)
+
+	conn := &recordConn{}
+	client.attach(conn)
+
+	return client, conn
+}
+
+// receive handles raw lines as if sent by the server
+func receive(t *testing.T, c *Client, lines ...string) {
+	t.Helper()
+
+	for _, line := range lines {
+		msg, err := irc.ParseMessage(line)
+		require.NoError(t, err)
+		require.NoError(t, c.Handle(*msg), line)
+	}
+}
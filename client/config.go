@@ -0,0 +1,654 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidConfig = errors.New("invalid config")
+
+// environment variables overriding the config file
+const (
+	EnvAddr                = "GRAL_IRC_ADDR"
+	EnvPassword            = "GRAL_IRC_PASSWORD"
+	EnvNick                = "GRAL_IRC_NICK"
+	EnvUser                = "GRAL_IRC_USER"
+	EnvRealName            = "GRAL_IRC_REALNAME"
+	EnvChannels            = "GRAL_IRC_CHANNELS"
+	EnvLogLevel            = "GRAL_IRC_LOG_LEVEL"
+	EnvCommands            = "GRAL_IRC_COMMANDS"
+	EnvCaps                = "GRAL_IRC_CAPS"
+	EnvPrefix              = "GRAL_IRC_COMMAND_PREFIX"
+	EnvMaxLines            = "GRAL_IRC_MAX_LINES"
+	EnvUserModes           = "GRAL_IRC_USER_MODES"
+	EnvAltNicks            = "GRAL_IRC_ALT_NICKS"
+	EnvRegainInterval      = "GRAL_IRC_REGAIN_INTERVAL"
+	EnvRegistrationTimeout = "GRAL_IRC_REGISTRATION_TIMEOUT"
+	EnvPingInterval        = "GRAL_IRC_PING_INTERVAL"
+	EnvPingTimeout         = "GRAL_IRC_PING_TIMEOUT"
+	EnvQuitMessage         = "GRAL_IRC_QUIT_MESSAGE"
+	EnvShutdownTimeout     = "GRAL_IRC_SHUTDOWN_TIMEOUT"
+
+	EnvFloodBurst        = "GRAL_IRC_FLOOD_BURST"
+	EnvFloodDelay        = "GRAL_IRC_FLOOD_DELAY"
+	EnvFloodPenaltyBytes = "GRAL_IRC_FLOOD_PENALTY_BYTES"
+
+	EnvSASLMechanism = "GRAL_IRC_SASL_MECHANISM"
+	EnvSASLUsername  = "GRAL_IRC_SASL_USERNAME"
+	EnvSASLPassword  = "GRAL_IRC_SASL_PASSWORD"
+	EnvSASLRequired  = "GRAL_IRC_SASL_REQUIRED"
+
+	EnvTLS           = "GRAL_IRC_TLS"
+	EnvTLSCAFile     = "GRAL_IRC_TLS_CA_FILE"
+	EnvTLSInsecure   = "GRAL_IRC_TLS_INSECURE"
+	EnvTLSServerName = "GRAL_IRC_TLS_SERVER_NAME"
+	EnvTLSCertFile   = "GRAL_IRC_TLS_CERT_FILE"
+	EnvTLSKeyFile    = "GRAL_IRC_TLS_KEY_FILE"
+)
+
+type ServerConfig struct {
+	Addr     string    `yaml:"addr" json:"addr" toml:"addr"`
+	Password string    `yaml:"password" json:"password" toml:"password"`
+	TLS      TLSConfig `yaml:"tls" json:"tls" toml:"tls"`
+}
+
+// Duration is a time.Duration read from strings such as "1m30s"
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+type ReconnectConfig struct {
+	MinDelay Duration `yaml:"min_delay" json:"min_delay" toml:"min_delay"`
+	MaxDelay Duration `yaml:"max_delay" json:"max_delay" toml:"max_delay"`
+}
+
+func (r ReconnectConfig) Backoff() Backoff {
+	return Backoff{
+		Min:    time.Duration(r.MinDelay),
+		Max:    time.Duration(r.MaxDelay),
+		Factor: 2,
+		Jitter: 0.5,
+	}
+}
+
+type ChannelConfig struct {
+	Name string `yaml:"name" json:"name" toml:"name"`
+	Key  string `yaml:"key" json:"key" toml:"key"`
+}
+
+type Config struct {
+	Server ServerConfig `yaml:"server" json:"server" toml:"server"`
+	Nick   string       `yaml:"nick" json:"nick" toml:"nick"`
+	// AltNicks are tried in order when the nick is taken, then the nick
+	// with a "_" suffix
+	AltNicks []string `yaml:"alt_nicks" json:"alt_nicks" toml:"alt_nicks"`
+	// RegainInterval is how often to retry the nick while using another
+	// one, 0 to only take it back when its holder leaves it
+	RegainInterval Duration `yaml:"regain_interval" json:"regain_interval" toml:"regain_interval"`
+	User           string   `yaml:"user" json:"user" toml:"user"`
+	RealName       string   `yaml:"realname" json:"realname" toml:"realname"`
+	// UserModes are set once registered, such as "+B" for bots
+	UserModes string          `yaml:"user_modes" json:"user_modes" toml:"user_modes"`
+	Channels  []ChannelConfig `yaml:"channels" json:"channels" toml:"channels"`
+	LogLevel  string          `yaml:"log_level" json:"log_level" toml:"log_level"`
+	Reconnect ReconnectConfig `yaml:"reconnect" json:"reconnect" toml:"reconnect"`
+	// RegistrationTimeout drops connections the server doesn't welcome
+	// us on in time, 0 to wait forever
+	RegistrationTimeout Duration        `yaml:"registration_timeout" json:"registration_timeout" toml:"registration_timeout"`
+	Keepalive           KeepaliveConfig `yaml:"keepalive" json:"keepalive" toml:"keepalive"`
+	// QuitMessage is the QUIT reason when shutting down
+	QuitMessage string `yaml:"quit_message" json:"quit_message" toml:"quit_message"`
+	// ShutdownTimeout bounds the wait for pending lines to be sent and for
+	// the server to close the connection after QUIT
+	ShutdownTimeout Duration    `yaml:"shutdown_timeout" json:"shutdown_timeout" toml:"shutdown_timeout"`
+	Flood           FloodConfig `yaml:"flood" json:"flood" toml:"flood"`
+	// MaxLines caps the lines a long message is split into, the last one
+	// ending with "…more", 0 for no limit
+	MaxLines int `yaml:"max_lines" json:"max_lines" toml:"max_lines"`
+	// Caps lists the IRCv3 capabilities to request
+	Caps []string   `yaml:"caps" json:"caps" toml:"caps"`
+	SASL SASLConfig `yaml:"sasl" json:"sasl" toml:"sasl"`
+	// StrictParsing drops the messages that don't follow the protocol
+	StrictParsing bool `yaml:"strict_parsing" json:"strict_parsing" toml:"strict_parsing"`
+	// Commands lists the enabled bot commands, all of them when empty
+	Commands []string `yaml:"commands" json:"commands" toml:"commands"`
+	// CommandPrefix starts bot commands, they can also be addressed with
+	// the bot nick as in "gral: help"
+	CommandPrefix string `yaml:"command_prefix" json:"command_prefix" toml:"command_prefix"`
+	// Permissions restricts commands to nick!user@host masks
+	Permissions map[string][]string `yaml:"permissions" json:"permissions" toml:"permissions"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Server:         ServerConfig{Addr: "localhost:6667"},
+		Nick:           "[bot]Gral-irc",
+		RegainInterval: Duration(time.Minute),
+		User:           "[bot]Gral-irc",
+		RealName:       "gral.irc bot",
+		Channels:       []ChannelConfig{{Name: "#gral.irc"}},
+		LogLevel:       "debug",
+		Reconnect: ReconnectConfig{
+			MinDelay: Duration(time.Second),
+			MaxDelay: Duration(5 * time.Minute),
+		},
+		RegistrationTimeout: Duration(time.Minute),
+		Keepalive: KeepaliveConfig{
+			Interval: Duration(90 * time.Second),
+			Timeout:  Duration(time.Minute),
+		},
+		QuitMessage:     "gral.irc bot shutting down",
+		ShutdownTimeout: Duration(5 * time.Second),
+		Flood: FloodConfig{
+			Burst:        5,
+			Delay:        Duration(2 * time.Second),
+			PenaltyBytes: 256,
+		},
+		Caps:          slices.Clone(defaultCaps),
+		CommandPrefix: "!",
+	}
+}
+
+// LoadConfig builds the configuration from the defaults, the config file
+// given with -config, the environment and the command line flags, in that
+// order of precedence, and validates the result.
+func LoadConfig(args []string, getenv func(string) string) (Config, error) {
+	cfg := DefaultConfig()
+
+	fs := flag.NewFlagSet("gral.irc", flag.ContinueOnError)
+	path := fs.String("config", "", "path to a YAML, TOML or JSON config file")
+	addr := fs.String("addr", "", "server address (host:port)")
+	password := fs.String("password", "", "server password")
+	nick := fs.String("nick", "", "nickname")
+	altNicks := fs.String("alt-nicks", "", "comma separated nicknames used when the nickname is taken")
+	quitMessage := fs.String("quit-message", "", "QUIT reason when shutting down")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 0, "time to flush pending lines and wait for the server after QUIT")
+	pingInterval := fs.Duration("ping-interval", 0, "idle time before pinging the server, 0 disables the keepalive")
+	pingTimeout := fs.Duration("ping-timeout", 0, "time to wait for a ping answer before reconnecting")
+	registrationTimeout := fs.Duration("registration-timeout", 0, "drop connections not registered in time, 0 to wait forever")
+	regainInterval := fs.Duration("regain-interval", 0, "how often to retry the nickname while using another one, 0 to disable")
+	user := fs.String("user", "", "username")
+	realName := fs.String("realname", "", "real name")
+	userModes := fs.String("user-modes", "", "user modes set once registered, such as +B")
+	channels := fs.String("channels", "", "comma separated channels to join, #chan:key for keyed channels")
+	logLevel := fs.String("log-level", "", "log level (debug, info, warn, error)")
+	commands := fs.String("commands", "", "comma separated enabled bot commands")
+	prefix := fs.String("command-prefix", "", "bot commands prefix")
+	caps := fs.String("caps", "", "comma separated IRCv3 capabilities to request")
+	saslMechanism := fs.String("sasl-mechanism", "", "SASL mechanism (PLAIN, EXTERNAL, SCRAM-SHA-256)")
+	saslUsername := fs.String("sasl-username", "", "SASL account name")
+	saslPassword := fs.String("sasl-password", "", "SASL password")
+	strict := fs.Bool("strict", false, "drop messages that don't follow the protocol")
+	saslRequired := fs.Bool("sasl-required", false, "disconnect when SASL authentication fails")
+	useTLS := fs.Bool("tls", false, "connect over TLS")
+	tlsCAFile := fs.String("tls-ca-file", "", "PEM CA bundle used instead of the system roots")
+	tlsInsecure := fs.Bool("tls-insecure", false, "skip certificate verification")
+	tlsServerName := fs.String("tls-server-name", "", "override the TLS server name (SNI)")
+	tlsCertFile := fs.String("tls-cert-file", "", "client certificate for CertFP")
+	tlsKeyFile := fs.String("tls-key-file", "", "client certificate key")
+	maxLines := fs.Int("max-lines", 0, "maximum lines a long message is split into, 0 for no limit")
+	floodBurst := fs.Int("flood-burst", 0, "lines sent at once before throttling")
+	floodDelay := fs.Duration("flood-delay", 0, "time to earn back one line, 0 disables flood protection")
+	floodPenaltyBytes := fs.Int("flood-penalty-bytes", 0, "long lines cost one more line per this many bytes")
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := cfg.applyEnv(getenv); err != nil {
+		return Config{}, err
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Server.Addr = *addr
+		case "password":
+			cfg.Server.Password = *password
+		case "nick":
+			cfg.Nick = *nick
+		case "alt-nicks":
+			cfg.AltNicks = splitList(*altNicks)
+		case "regain-interval":
+			cfg.RegainInterval = Duration(*regainInterval)
+		case "registration-timeout":
+			cfg.RegistrationTimeout = Duration(*registrationTimeout)
+		case "quit-message":
+			cfg.QuitMessage = *quitMessage
+		case "shutdown-timeout":
+			cfg.ShutdownTimeout = Duration(*shutdownTimeout)
+		case "ping-interval":
+			cfg.Keepalive.Interval = Duration(*pingInterval)
+		case "ping-timeout":
+			cfg.Keepalive.Timeout = Duration(*pingTimeout)
+		case "user":
+			cfg.User = *user
+		case "realname":
+			cfg.RealName = *realName
+		case "user-modes":
+			cfg.UserModes = *userModes
+		case "channels":
+			cfg.Channels, err = parseChannelList(*channels)
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		case "commands":
+			cfg.Commands = splitList(*commands)
+		case "command-prefix":
+			cfg.CommandPrefix = *prefix
+		case "caps":
+			cfg.Caps = splitList(*caps)
+		case "sasl-mechanism":
+			cfg.SASL.Mechanism = *saslMechanism
+		case "sasl-username":
+			cfg.SASL.Username = *saslUsername
+		case "sasl-password":
+			cfg.SASL.Password = *saslPassword
+		case "sasl-required":
+			cfg.SASL.Required = *saslRequired
+		case "strict":
+			cfg.StrictParsing = *strict
+		case "tls":
+			cfg.Server.TLS.Enabled = *useTLS
+		case "tls-ca-file":
+			cfg.Server.TLS.CAFile = *tlsCAFile
+		case "tls-insecure":
+			cfg.Server.TLS.Insecure = *tlsInsecure
+		case "tls-server-name":
+			cfg.Server.TLS.ServerName = *tlsServerName
+		case "tls-cert-file":
+			cfg.Server.TLS.CertFile = *tlsCertFile
+		case "tls-key-file":
+			cfg.Server.TLS.KeyFile = *tlsKeyFile
+		case "max-lines":
+			cfg.MaxLines = *maxLines
+		case "flood-burst":
+			cfg.Flood.Burst = *floodBurst
+		case "flood-delay":
+			cfg.Flood.Delay = Duration(*floodDelay)
+		case "flood-penalty-bytes":
+			cfg.Flood.PenaltyBytes = *floodPenaltyBytes
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	case ".json":
+		err = json.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("unsupported config file format: %s: %w", path, ErrInvalidConfig)
+	}
+	if err != nil {
+		return fmt.Errorf("error decoding config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) applyEnv(getenv func(string) string) error {
+	if v := getenv(EnvAddr); v != "" {
+		c.Server.Addr = v
+	}
+	if v := getenv(EnvPassword); v != "" {
+		c.Server.Password = v
+	}
+	if v := getenv(EnvNick); v != "" {
+		c.Nick = v
+	}
+	if v := getenv(EnvUser); v != "" {
+		c.User = v
+	}
+	if v := getenv(EnvRealName); v != "" {
+		c.RealName = v
+	}
+	if v := getenv(EnvAltNicks); v != "" {
+		c.AltNicks = splitList(v)
+	}
+	if v := getenv(EnvRegainInterval); v != "" {
+		if err := c.RegainInterval.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("%s: %w", EnvRegainInterval, err)
+		}
+	}
+	if v := getenv(EnvRegistrationTimeout); v != "" {
+		if err := c.RegistrationTimeout.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("%s: %w", EnvRegistrationTimeout, err)
+		}
+	}
+	if v := getenv(EnvQuitMessage); v != "" {
+		c.QuitMessage = v
+	}
+	if v := getenv(EnvShutdownTimeout); v != "" {
+		if err := c.ShutdownTimeout.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("%s: %w", EnvShutdownTimeout, err)
+		}
+	}
+	if v := getenv(EnvPingInterval); v != "" {
+		if err := c.Keepalive.Interval.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("%s: %w", EnvPingInterval, err)
+		}
+	}
+	if v := getenv(EnvPingTimeout); v != "" {
+		if err := c.Keepalive.Timeout.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("%s: %w", EnvPingTimeout, err)
+		}
+	}
+	if v := getenv(EnvUserModes); v != "" {
+		c.UserModes = v
+	}
+	if v := getenv(EnvChannels); v != "" {
+		channels, err := parseChannelList(v)
+		if err != nil {
+			return err
+		}
+		c.Channels = channels
+	}
+	if v := getenv(EnvLogLevel); v != "" {
+		c.LogLevel = v
+	}
+	if v := getenv(EnvCommands); v != "" {
+		c.Commands = splitList(v)
+	}
+	if v := getenv(EnvCaps); v != "" {
+		c.Caps = splitList(v)
+	}
+	if v := getenv(EnvPrefix); v != "" {
+		c.CommandPrefix = v
+	}
+	if v := getenv(EnvMaxLines); v != "" {
+		maxLines, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvMaxLines, err)
+		}
+		c.MaxLines = maxLines
+	}
+	if v := getenv(EnvFloodBurst); v != "" {
+		burst, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvFloodBurst, err)
+		}
+		c.Flood.Burst = burst
+	}
+	if v := getenv(EnvFloodDelay); v != "" {
+		if err := c.Flood.Delay.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("%s: %w", EnvFloodDelay, err)
+		}
+	}
+	if v := getenv(EnvFloodPenaltyBytes); v != "" {
+		penalty, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvFloodPenaltyBytes, err)
+		}
+		c.Flood.PenaltyBytes = penalty
+	}
+	if v := getenv(EnvSASLMechanism); v != "" {
+		c.SASL.Mechanism = v
+	}
+	if v := getenv(EnvSASLUsername); v != "" {
+		c.SASL.Username = v
+	}
+	if v := getenv(EnvSASLPassword); v != "" {
+		c.SASL.Password = v
+	}
+	if v := getenv(EnvSASLRequired); v != "" {
+		required, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvSASLRequired, err)
+		}
+		c.SASL.Required = required
+	}
+	if v := getenv(EnvTLS); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvTLS, err)
+		}
+		c.Server.TLS.Enabled = enabled
+	}
+	if v := getenv(EnvTLSCAFile); v != "" {
+		c.Server.TLS.CAFile = v
+	}
+	if v := getenv(EnvTLSInsecure); v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvTLSInsecure, err)
+		}
+		c.Server.TLS.Insecure = insecure
+	}
+	if v := getenv(EnvTLSServerName); v != "" {
+		c.Server.TLS.ServerName = v
+	}
+	if v := getenv(EnvTLSCertFile); v != "" {
+		c.Server.TLS.CertFile = v
+	}
+	if v := getenv(EnvTLSKeyFile); v != "" {
+		c.Server.TLS.KeyFile = v
+	}
+
+	return nil
+}
+
+// Validate reports every problem found in the config at once.
+func (c Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("server.addr %q: %w", c.Server.Addr, err))
+	}
+
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("server.tls: cert_file and key_file must be set together"))
+	}
+
+	switch strings.ToUpper(c.SASL.Mechanism) {
+	case "":
+	case SASLPlain, SASLScramSHA256:
+		if c.SASL.Username == "" || c.SASL.Password == "" {
+			errs = append(errs, fmt.Errorf("sasl: %s needs a username and a password", c.SASL.Mechanism))
+		}
+	case SASLExternal:
+		if !c.Server.TLS.Enabled || c.Server.TLS.CertFile == "" {
+			errs = append(errs, errors.New("sasl: EXTERNAL needs TLS with a client certificate"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("sasl: %s: %w", c.SASL.Mechanism, ErrUnknownMech))
+	}
+
+	if err := validateNick(c.Nick); err != nil {
+		errs = append(errs, fmt.Errorf("nick %q: %w", c.Nick, err))
+	}
+
+	for _, nick := range c.AltNicks {
+		if err := validateNick(nick); err != nil {
+			errs = append(errs, fmt.Errorf("alt_nicks %q: %w", nick, err))
+		}
+	}
+
+	if c.RegainInterval < 0 {
+		errs = append(errs, errors.New("regain_interval: must not be negative"))
+	}
+
+	if c.User == "" || strings.ContainsAny(c.User, " @\r\n\x00") {
+		errs = append(errs, fmt.Errorf("user %q: must be a non empty word", c.User))
+	}
+
+	if c.UserModes != "" {
+		if err := validateUserModes(c.UserModes); err != nil {
+			errs = append(errs, fmt.Errorf("user_modes %q: %w", c.UserModes, err))
+		}
+	}
+
+	for _, ch := range c.Channels {
+		if err := validateChannel(ch.Name); err != nil {
+			errs = append(errs, fmt.Errorf("channel %q: %w", ch.Name, err))
+		}
+		if strings.ContainsAny(ch.Key, " ,\r\n\x00") {
+			errs = append(errs, fmt.Errorf("channel %q: invalid key", ch.Name))
+		}
+	}
+
+	if c.Reconnect.MinDelay <= 0 || c.Reconnect.MaxDelay < c.Reconnect.MinDelay {
+		errs = append(errs, errors.New("reconnect: min_delay must be positive and not above max_delay"))
+	}
+
+	if c.RegistrationTimeout < 0 {
+		errs = append(errs, errors.New("registration_timeout: must not be negative"))
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout: must be positive"))
+	}
+
+	if strings.ContainsAny(c.QuitMessage, "\r\n\x00") {
+		errs = append(errs, errors.New("quit_message: must be a single line"))
+	}
+
+	if c.Keepalive.Interval < 0 || (c.Keepalive.Enabled() && c.Keepalive.Timeout <= 0) {
+		errs = append(errs, errors.New("keepalive: interval must not be negative, timeout must be positive"))
+	}
+
+	if c.Flood.Delay < 0 || c.Flood.PenaltyBytes < 0 || (c.Flood.Enabled() && c.Flood.Burst < 1) {
+		errs = append(errs, errors.New("flood: burst must be at least 1, delay and penalty_bytes must not be negative"))
+	}
+
+	if c.MaxLines < 0 {
+		errs = append(errs, errors.New("max_lines: must not be negative"))
+	}
+
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, name := range c.Commands {
+		if !slices.Contains(builtinCommands, name) {
+			errs = append(errs, fmt.Errorf("unknown command %q", name))
+		}
+	}
+
+	if strings.ContainsAny(c.CommandPrefix, " \r\n\x00") {
+		errs = append(errs, fmt.Errorf("command_prefix %q: must not contain spaces", c.CommandPrefix))
+	}
+
+	for name := range c.Permissions {
+		if !slices.Contains(builtinCommands, name) {
+			errs = append(errs, fmt.Errorf("permissions: unknown command %q", name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func (c Config) SlogLevel() slog.Level {
+	level, _ := parseLogLevel(c.LogLevel)
+	return level
+}
+
+// CommandEnabled reports whether the bot command name may be used
+func (c Config) CommandEnabled(name string) bool {
+	return len(c.Commands) == 0 || slices.Contains(c.Commands, name)
+}
+
+func parseLogLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("log_level %q: must be debug, info, warn or error", level)
+	}
+	return l, nil
+}
+
+func validateNick(nick string) error {
+	if nick == "" {
+		return errors.New("must not be empty")
+	}
+	if strings.ContainsAny(nick, " ,*?!@.:#&\r\n\x00") {
+		return errors.New("contains forbidden characters")
+	}
+	if nick[0] == '$' || nick[0] == '-' || (nick[0] >= '0' && nick[0] <= '9') {
+		return errors.New("must not start with a digit, '-' or '$'")
+	}
+	return nil
+}
+
+func validateChannel(name string) error {
+	if name == "" || !strings.ContainsRune("#&+!", rune(name[0])) {
+		return errors.New("must start with one of #&+!")
+	}
+	if strings.ContainsAny(name, " ,:\a\r\n\x00") {
+		return errors.New("contains forbidden characters")
+	}
+	return nil
+}
+
+// parseChannelList parses "#a,#b:key" into channel configs
+func parseChannelList(in string) ([]ChannelConfig, error) {
+	channels := make([]ChannelConfig, 0)
+	for _, item := range splitList(in) {
+		name, key, _ := strings.Cut(item, ":")
+		if name == "" {
+			return nil, fmt.Errorf("channel list %q: %w", in, ErrInvalidConfig)
+		}
+		channels = append(channels, ChannelConfig{Name: name, Key: key})
+	}
+	return channels, nil
+}
+
+func splitList(in string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(in, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
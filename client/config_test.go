@@ -0,0 +1,160 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "bot.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+server:
+  addr: irc.example.net:6667
+  password: hunter2
+nick: gral
+channels:
+  - name: "#a"
+  - name: "#b"
+    key: secret
+log_level: info
+commands: [topic]
+`), 0o600))
+
+	jsonPath := filepath.Join(dir, "bot.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"nick": "jsonbot", "caps": ["sasl"]}`), 0o600))
+
+	tomlPath := filepath.Join(dir, "bot.toml")
+	require.NoError(t, os.WriteFile(tomlPath, []byte(`
+nick = "tomlbot"
+alt_nicks = ["tomlbot_"]
+registration_timeout = "30s"
+
+[server]
+addr = "irc.example.net:6697"
+
+[server.tls]
+enabled = true
+
+[[channels]]
+name = "#a"
+
+[[channels]]
+name = "#b"
+key = "secret"
+`), 0o600))
+
+	noEnv := func(string) string { return "" }
+
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := LoadConfig(nil, noEnv)
+		require.NoError(t, err)
+		assert.Equal(t, DefaultConfig(), cfg)
+	})
+
+	t.Run("yaml file", func(t *testing.T) {
+		cfg, err := LoadConfig([]string{"-config", yamlPath}, noEnv)
+		require.NoError(t, err)
+		assert.Equal(t, "irc.example.net:6667", cfg.Server.Addr)
+		assert.Equal(t, "hunter2", cfg.Server.Password)
+		assert.Equal(t, "gral", cfg.Nick)
+		assert.Equal(t, []ChannelConfig{{Name: "#a"}, {Name: "#b", Key: "secret"}}, cfg.Channels)
+		assert.True(t, cfg.CommandEnabled("topic"))
+		assert.False(t, cfg.CommandEnabled("users"))
+	})
+
+	t.Run("json file", func(t *testing.T) {
+		cfg, err := LoadConfig([]string{"-config", jsonPath}, noEnv)
+		require.NoError(t, err)
+		assert.Equal(t, "jsonbot", cfg.Nick)
+		assert.Equal(t, []string{"sasl"}, cfg.Caps)
+
+		// decoding into the defaults leaves them alone
+		assert.Equal(t, []string{"message-tags", "server-time", "multi-prefix"}, DefaultConfig().Caps)
+	})
+
+	t.Run("toml file", func(t *testing.T) {
+		cfg, err := LoadConfig([]string{"-config", tomlPath}, noEnv)
+		require.NoError(t, err)
+		assert.Equal(t, "tomlbot", cfg.Nick)
+		assert.Equal(t, []string{"tomlbot_"}, cfg.AltNicks)
+		assert.Equal(t, Duration(30*time.Second), cfg.RegistrationTimeout)
+		assert.Equal(t, "irc.example.net:6697", cfg.Server.Addr)
+		assert.True(t, cfg.Server.TLS.Enabled)
+		assert.Equal(t, []ChannelConfig{{Name: "#a"}, {Name: "#b", Key: "secret"}}, cfg.Channels)
+	})
+
+	t.Run("env and flags override file", func(t *testing.T) {
+		env := map[string]string{
+			EnvNick:     "envbot",
+			EnvAddr:     "env.example.net:6667",
+			EnvChannels: "#x,#y:key",
+		}
+		cfg, err := LoadConfig(
+			[]string{"-config", yamlPath, "-nick", "flagbot"},
+			func(k string) string { return env[k] },
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "flagbot", cfg.Nick)
+		assert.Equal(t, "env.example.net:6667", cfg.Server.Addr)
+		assert.Equal(t, []ChannelConfig{{Name: "#x"}, {Name: "#y", Key: "key"}}, cfg.Channels)
+	})
+
+	t.Run("validation errors", func(t *testing.T) {
+		_, err := LoadConfig(
+			[]string{"-addr", "nohost", "-nick", "1bad", "-channels", "nochan", "-commands", "nope"},
+			noEnv,
+		)
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "server.addr")
+		assert.ErrorContains(t, err, "nick")
+		assert.ErrorContains(t, err, "channel")
+		assert.ErrorContains(t, err, "unknown command")
+	})
+
+	t.Run("flood", func(t *testing.T) {
+		env := map[string]string{EnvFloodDelay: "500ms", EnvFloodBurst: "3"}
+		cfg, err := LoadConfig([]string{"-flood-burst", "10"}, func(k string) string { return env[k] })
+		require.NoError(t, err)
+		assert.Equal(t, FloodConfig{Burst: 10, Delay: Duration(500 * time.Millisecond), PenaltyBytes: 256}, cfg.Flood)
+
+		cfg, err = LoadConfig([]string{"-flood-delay", "0", "-flood-burst", "0"}, noEnv)
+		require.NoError(t, err)
+		assert.False(t, cfg.Flood.Enabled())
+
+		_, err = LoadConfig([]string{"-flood-burst", "0"}, noEnv)
+		assert.ErrorContains(t, err, "flood")
+	})
+
+	t.Run("user modes", func(t *testing.T) {
+		env := map[string]string{EnvUserModes: "+iB"}
+		cfg, err := LoadConfig(nil, func(k string) string { return env[k] })
+		require.NoError(t, err)
+		assert.Equal(t, "+iB", cfg.UserModes)
+
+		_, err = LoadConfig([]string{"-user-modes", "B"}, noEnv)
+		assert.ErrorContains(t, err, "user_modes")
+	})
+
+	t.Run("alt nicks", func(t *testing.T) {
+		env := map[string]string{EnvAltNicks: "gral2,gral3", EnvRegainInterval: "30s"}
+		cfg, err := LoadConfig(nil, func(k string) string { return env[k] })
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gral2", "gral3"}, cfg.AltNicks)
+		assert.Equal(t, Duration(30*time.Second), cfg.RegainInterval)
+
+		_, err = LoadConfig([]string{"-alt-nicks", "gral2,#bad"}, noEnv)
+		assert.ErrorContains(t, err, "alt_nicks")
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := LoadConfig([]string{"-config", filepath.Join(dir, "bot.ini")}, noEnv)
+		assert.Error(t, err)
+	})
+}
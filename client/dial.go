@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+var ErrNoCertificates = errors.New("no certificates found")
+
+type TLSConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled" toml:"enabled"`
+	// CAFile is a PEM bundle used instead of the system roots
+	CAFile string `yaml:"ca_file" json:"ca_file" toml:"ca_file"`
+	// Insecure disables certificate verification, for test servers only
+	Insecure bool `yaml:"insecure" json:"insecure" toml:"insecure"`
+	// ServerName overrides the SNI and verified host name
+	ServerName string `yaml:"server_name" json:"server_name" toml:"server_name"`
+	// CertFile and KeyFile hold a client certificate for CertFP
+	CertFile string `yaml:"cert_file" json:"cert_file" toml:"cert_file"`
+	KeyFile  string `yaml:"key_file" json:"key_file" toml:"key_file"`
+}
+
+// ClientConfig builds the crypto/tls config used to connect to host
+func (t TLSConfig) ClientConfig(host string) (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: t.Insecure,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if t.ServerName != "" {
+		conf.ServerName = t.ServerName
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA file %s: %w", t.CAFile, ErrNoCertificates)
+		}
+		conf.RootCAs = pool
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return conf, nil
+}
+
+// Dial connects to the server, over TLS when enabled
+func Dial(ctx context.Context, server ServerConfig) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+	if !server.TLS.Enabled {
+		return dialer.DialContext(ctx, "tcp", server.Addr)
+	}
+
+	host, _, err := net.SplitHostPort(server.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing address: %w", err)
+	}
+
+	conf, err := server.TLS.ClientConfig(host)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsDialer := &tls.Dialer{NetDialer: dialer, Config: conf}
+	conn, err := tlsDialer.DialContext(ctx, "tcp", server.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing tls: %w", err)
+	}
+
+	return conn, nil
+}
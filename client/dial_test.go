@@ -0,0 +1,212 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	tlsCert tls.Certificate
+}
+
+// write the certificate and its key as PEM files in dir
+func (tc testCert) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(tc.key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tc.cert.Raw}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+func newTestCert(t *testing.T, tmpl *x509.Certificate, parent *testCert) testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := tmpl, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return testCert{
+		cert:    cert,
+		key:     key,
+		tlsCert: tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+	}
+}
+
+// start a TLS listener answering every connection with a line containing
+// the SHA-256 fingerprint of the client certificate, if any
+func newTLSServer(t *testing.T, conf *tls.Config) string {
+	t.Helper()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", conf)
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tlsConn := conn.(*tls.Conn)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				fp := "none"
+				if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+					sum := sha256.Sum256(certs[0].Raw)
+					fp = hex.EncodeToString(sum[:])
+				}
+				_, _ = conn.Write([]byte(fp + "\r\n"))
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestDialTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	caFile, _ := ca.write(t, dir, "ca")
+
+	server := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "irc.test"},
+		DNSNames:    []string{"irc.test"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &ca)
+
+	client := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "gral"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, nil)
+	certFile, keyFile := client.write(t, dir, "client")
+	clientFP := sha256.Sum256(client.cert.Raw)
+
+	addr := newTLSServer(t, &tls.Config{
+		Certificates: []tls.Certificate{server.tlsCert},
+		ClientAuth:   tls.RequestClientCert,
+	})
+
+	readLine := func(t *testing.T, conn net.Conn) string {
+		t.Helper()
+		buf := make([]byte, 128)
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	cases := []struct {
+		name    string
+		tls     TLSConfig
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "system roots reject test ca",
+			tls:     TLSConfig{Enabled: true, ServerName: "irc.test"},
+			wantErr: true,
+		},
+		{
+			name: "custom ca with sni override",
+			tls:  TLSConfig{Enabled: true, CAFile: caFile, ServerName: "irc.test"},
+			want: "none\r\n",
+		},
+		{
+			name:    "custom ca with wrong host name",
+			tls:     TLSConfig{Enabled: true, CAFile: caFile},
+			wantErr: true,
+		},
+		{
+			name: "insecure",
+			tls:  TLSConfig{Enabled: true, Insecure: true},
+			want: "none\r\n",
+		},
+		{
+			name: "client certificate",
+			tls: TLSConfig{
+				Enabled:    true,
+				CAFile:     caFile,
+				ServerName: "irc.test",
+				CertFile:   certFile,
+				KeyFile:    keyFile,
+			},
+			want: hex.EncodeToString(clientFP[:]) + "\r\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			conn, err := Dial(ctx, ServerConfig{Addr: addr, TLS: c.tls})
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer conn.Close()
+
+			assert.Equal(t, c.want, readLine(t, conn))
+		})
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.pem")
+	require.NoError(t, os.WriteFile(bad, []byte("not a certificate"), 0o600))
+
+	_, err := TLSConfig{CAFile: bad}.ClientConfig("irc.test")
+	assert.ErrorIs(t, err, ErrNoCertificates)
+
+	_, err = TLSConfig{CertFile: bad, KeyFile: bad}.ClientConfig("irc.test")
+	assert.Error(t, err)
+}
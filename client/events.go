@@ -0,0 +1,126 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// ErrStopPropagation returned by a handler keeps the next handlers of the
+// event from being called
+var ErrStopPropagation = errors.New("stop propagation")
+
+// EventAll subscribes to every command, numeric and client event
+const EventAll = "*"
+
+// handler priorities, higher runs first
+const (
+	PriorityHigh    = 100
+	PriorityDefault = 0
+	PriorityLow     = -100
+)
+
+type listener struct {
+	id       uint64
+	priority int
+	h        Handler
+}
+
+// Dispatcher calls any number of handlers per command, numeric name or
+// client event
+type Dispatcher struct {
+	mu        sync.RWMutex
+	listeners map[string][]*listener
+	nextID    uint64
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{listeners: make(map[string][]*listener)}
+}
+
+// Subscription removes its handler with Unsubscribe
+type Subscription struct {
+	d     *Dispatcher
+	event string
+	id    uint64
+}
+
+func (s Subscription) Unsubscribe() {
+	if s.d == nil {
+		return
+	}
+
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+
+	s.d.listeners[s.event] = slices.DeleteFunc(s.d.listeners[s.event], func(l *listener) bool {
+		return l.id == s.id
+	})
+	if len(s.d.listeners[s.event]) == 0 {
+		delete(s.d.listeners, s.event)
+	}
+}
+
+// canonicalEvent names numerics the way handlers are looked up: "001"
+// becomes "RPL_WELCOME"
+func canonicalEvent(event string) string {
+	if name, ok := irc.Commands[event]; ok {
+		return name
+	}
+	return event
+}
+
+// Subscribe adds a handler for the event, handlers of the same priority
+// run in subscription order
+func (d *Dispatcher) Subscribe(event string, priority int, h Handler) Subscription {
+	event = canonicalEvent(event)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.nextID++
+	l := &listener{id: d.nextID, priority: priority, h: h}
+
+	listeners := d.listeners[event]
+	i := slices.IndexFunc(listeners, func(other *listener) bool { return other.priority < priority })
+	if i == -1 {
+		i = len(listeners)
+	}
+	d.listeners[event] = slices.Insert(listeners, i, l)
+
+	return Subscription{d: d, event: event, id: l.id}
+}
+
+// Dispatch calls the handlers of the event along with the EventAll ones,
+// by priority. Errors don't stop the dispatch and are joined, except
+// ErrStopPropagation. It returns ErrUnknwonCommand when nothing but
+// EventAll handlers listens to the event.
+func (d *Dispatcher) Dispatch(event string, msg irc.Msg) error {
+	d.mu.RLock()
+	specific := d.listeners[event]
+	listeners := slices.Concat(specific, d.listeners[EventAll])
+	d.mu.RUnlock()
+
+	// stable, so specific handlers go first within a priority
+	slices.SortStableFunc(listeners, func(a, b *listener) int { return b.priority - a.priority })
+
+	var errs []error
+	for _, l := range listeners {
+		err := l.h(msg)
+		if errors.Is(err, ErrStopPropagation) {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(specific) == 0 {
+		errs = append(errs, fmt.Errorf("unknown command: %s: %w", event, ErrUnknwonCommand))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+func TestDispatcher(t *testing.T) {
+	d := NewDispatcher()
+	calls := make([]string, 0)
+	record := func(name string, err error) Handler {
+		return func(irc.Msg) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	d.Subscribe("PRIVMSG", PriorityDefault, record("default-1", nil))
+	d.Subscribe("PRIVMSG", PriorityLow, record("low", nil))
+	d.Subscribe("PRIVMSG", PriorityHigh, record("high", nil))
+	d.Subscribe("PRIVMSG", PriorityDefault, record("default-2", nil))
+	all := d.Subscribe(EventAll, PriorityDefault, record("all", nil))
+
+	assert.NoError(t, d.Dispatch("PRIVMSG", irc.Msg{}))
+	assert.Equal(t, []string{"high", "default-1", "default-2", "all", "low"}, calls)
+
+	// wildcard handlers alone don't make a command known
+	calls = calls[:0]
+	assert.ErrorIs(t, d.Dispatch("NOTICE", irc.Msg{}), ErrUnknwonCommand)
+	assert.Equal(t, []string{"all"}, calls)
+
+	all.Unsubscribe()
+	calls = calls[:0]
+	assert.NoError(t, d.Dispatch("PRIVMSG", irc.Msg{}))
+	assert.Equal(t, []string{"high", "default-1", "default-2", "low"}, calls)
+}
+
+func TestDispatcherStopAndErrors(t *testing.T) {
+	d := NewDispatcher()
+	calls := 0
+	count := func(irc.Msg) error { calls++; return nil }
+	errBoom := errors.New("boom")
+
+	d.Subscribe("JOIN", PriorityHigh, func(irc.Msg) error { return errBoom })
+	d.Subscribe("JOIN", PriorityDefault, count)
+	stop := d.Subscribe("JOIN", PriorityHigh-1, func(irc.Msg) error { return ErrStopPropagation })
+
+	// errors are reported, stopping isn't one
+	assert.ErrorIs(t, d.Dispatch("JOIN", irc.Msg{}), errBoom)
+	assert.Equal(t, 0, calls)
+
+	stop.Unsubscribe()
+	assert.ErrorIs(t, d.Dispatch("JOIN", irc.Msg{}), errBoom)
+	assert.Equal(t, 1, calls)
+}
+
+func TestClientOn(t *testing.T) {
+	client, _ := newTestClient(t, DefaultConfig())
+
+	welcomed := 0
+	sub := client.On("001", func(irc.Msg) error { welcomed++; return nil })
+
+	receive(t, client, ":irc.test 001 gral :Welcome")
+	assert.Equal(t, 1, welcomed)
+
+	sub.Unsubscribe()
+	receive(t, client, ":irc.test 001 gral :Welcome")
+	assert.Equal(t, 1, welcomed)
+
+	// hooking a command before the built-in handler
+	var seenUsers int
+	client.OnPriority("JOIN", PriorityHigh, func(msg irc.Msg) error {
+		if ch, ok := client.channels[msg.Target]; ok {
+			seenUsers = len(ch.Users)
+		}
+		return nil
+	})
+	receive(t, client, ":gral!user@host JOIN #chan", ":other!user@host JOIN #chan")
+	assert.Equal(t, 1, seenUsers)
+}
+
+// built-in handlers must not panic on lines missing params
+func TestShortMessagesDontPanic(t *testing.T) {
+	commands := []string{"PING", "PONG", "ERROR", "JOIN", "PRIVMSG", "NOTICE", "PART", "QUIT", "NICK", "MODE", "KICK", "TOPIC", "CAP", "AUTHENTICATE"}
+	for code := range irc.Commands {
+		commands = append(commands, code)
+	}
+
+	for _, fill := range []string{"gral", "#chan"} {
+		for _, command := range commands {
+			for n := 0; n < 4; n++ {
+				cfg := DefaultConfig()
+				cfg.Nick = "gral"
+				client, _ := newTestClient(t, cfg)
+				receive(t, client, ":gral!gral@host JOIN #chan")
+
+				args := make([]string, n)
+				for i := range args {
+					args[i] = fill
+				}
+				msg := irc.Msg{Prefix: "gral!gral@host", Nick: "gral", Command: command, Args: args}
+				if n > 0 {
+					msg.Target = args[0]
+					msg.Trailing = args[n-1]
+				}
+				assert.NotPanics(t, func() { _ = client.Handle(msg) }, "%s with %d params", command, n)
+			}
+		}
+	}
+}
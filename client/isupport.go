@@ -0,0 +1,259 @@
+package client
+
+import (
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// ChanModes are the channel modes of CHANMODES by kind
+type ChanModes struct {
+	// List modes such as b add or remove a nick or mask, they take a
+	// parameter and have no parameter when listing
+	List string
+	// Param modes such as k always take a parameter
+	Param string
+	// SetParam modes such as l take a parameter when set only
+	SetParam string
+	// Flag modes such as m never take a parameter
+	Flag string
+}
+
+// ServerInfo holds the features advertised by the server in RPL_ISUPPORT
+type ServerInfo struct {
+	Network string
+	// ServerName, ServerVersion and Created come from RPL_YOURHOST,
+	// RPL_CREATED and RPL_MYINFO
+	ServerName    string
+	ServerVersion string
+	Created       string
+	// AvailableUserModes and AvailableChanModes are the modes of
+	// RPL_MYINFO, such as "iowB"
+	AvailableUserModes string
+	AvailableChanModes string
+	CaseMapping        irc.CaseMapping
+	// ChanTypes are the characters channel names start with
+	ChanTypes string
+	// PrefixModes are the membership modes such as "ov", from the highest,
+	// PrefixSymbols the matching symbols such as "@+"
+	PrefixModes   string
+	PrefixSymbols string
+	ChanModes     ChanModes
+	// StatusMsg are the prefix symbols allowed before a channel name to
+	// message only its members with that status, such as "@#chan"
+	StatusMsg string
+	// Modes is the maximum number of modes with a parameter in a MODE
+	Modes int
+	// the maximum lengths, 0 when unknown
+	NickLen    int
+	ChannelLen int
+	TopicLen   int
+	KickLen    int
+	AwayLen    int
+	// TargMax is the maximum number of targets by command, 0 for no limit
+	TargMax map[string]int
+	// Tokens holds every token as sent by the server
+	Tokens map[string]string
+}
+
+// DefaultServerInfo returns the features assumed until the server
+// advertises its own
+func DefaultServerInfo() ServerInfo {
+	return ServerInfo{
+		CaseMapping:   irc.CaseMappingRFC1459,
+		ChanTypes:     "#&",
+		PrefixModes:   "ov",
+		PrefixSymbols: "@+",
+		ChanModes:     ChanModes{List: "b", Param: "k", SetParam: "l", Flag: "imnpst"},
+		Modes:         3,
+		TargMax:       make(map[string]int),
+		Tokens:        make(map[string]string),
+	}
+}
+
+func (s ServerInfo) clone() ServerInfo {
+	s.TargMax = maps.Clone(s.TargMax)
+	s.Tokens = maps.Clone(s.Tokens)
+	return s
+}
+
+// IsChannel reports whether name is a channel rather than a nick
+func (s ServerInfo) IsChannel(name string) bool {
+	return name != "" && strings.IndexByte(s.ChanTypes, name[0]) >= 0
+}
+
+// SplitStatusMsg splits a target such as "@#chan" into the status
+// prefixes and the channel
+func (s ServerInfo) SplitStatusMsg(target string) (string, string) {
+	channel := strings.TrimLeft(target, s.StatusMsg)
+	if !s.IsChannel(channel) {
+		return "", target
+	}
+	return target[:len(target)-len(channel)], channel
+}
+
+// SplitPrefix splits a RPL_NAMREPLY entry such as "@+nick" into its
+// membership symbols and the nick
+func (s ServerInfo) SplitPrefix(entry string) (string, string) {
+	nick := strings.TrimLeft(entry, s.PrefixSymbols)
+	return entry[:len(entry)-len(nick)], nick
+}
+
+// PrefixMode returns the membership mode of a prefix symbol, such as 'o'
+// for '@'
+func (s ServerInfo) PrefixMode(symbol byte) (byte, bool) {
+	i := strings.IndexByte(s.PrefixSymbols, symbol)
+	if i < 0 || i >= len(s.PrefixModes) {
+		return 0, false
+	}
+	return s.PrefixModes[i], true
+}
+
+// unescapeISupport decodes the \xHH escapes of ISUPPORT values
+func unescapeISupport(value string) string {
+	if !strings.Contains(value, `\x`) {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+3 < len(value) && value[i+1] == 'x' {
+			if n, err := strconv.ParseUint(value[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// atoi parses a length token, 0 meaning no limit for missing values
+func atoi(value string) int {
+	n, _ := strconv.Atoi(value)
+	return max(n, 0)
+}
+
+// apply updates the info with a token, a "-NAME" token restores the
+// default value
+func (s *ServerInfo) apply(name, value string) {
+	if removed, ok := strings.CutPrefix(name, "-"); ok {
+		delete(s.Tokens, removed)
+		*s = s.withDefault(removed, DefaultServerInfo())
+		return
+	}
+
+	s.Tokens[name] = value
+
+	switch name {
+	case "NETWORK":
+		s.Network = value
+	case "CASEMAPPING":
+		s.CaseMapping = irc.ParseCaseMapping(value)
+	case "CHANTYPES":
+		s.ChanTypes = value
+	case "PREFIX":
+		// (ov)@+
+		if modes, symbols, ok := strings.Cut(strings.TrimPrefix(value, "("), ")"); ok && len(modes) == len(symbols) {
+			s.PrefixModes, s.PrefixSymbols = modes, symbols
+		} else if value == "" {
+			s.PrefixModes, s.PrefixSymbols = "", ""
+		}
+	case "CHANMODES":
+		kinds := strings.SplitN(value, ",", 4)
+		kinds = append(kinds, make([]string, 4-len(kinds))...)
+		s.ChanModes = ChanModes{List: kinds[0], Param: kinds[1], SetParam: kinds[2], Flag: kinds[3]}
+	case "STATUSMSG":
+		s.StatusMsg = value
+	case "MODES":
+		s.Modes = atoi(value)
+	case "NICKLEN":
+		s.NickLen = atoi(value)
+	case "CHANNELLEN":
+		s.ChannelLen = atoi(value)
+	case "TOPICLEN":
+		s.TopicLen = atoi(value)
+	case "KICKLEN":
+		s.KickLen = atoi(value)
+	case "AWAYLEN":
+		s.AwayLen = atoi(value)
+	case "TARGMAX":
+		s.TargMax = make(map[string]int)
+		for _, item := range strings.Split(value, ",") {
+			if command, limit, ok := strings.Cut(item, ":"); ok {
+				s.TargMax[strings.ToUpper(command)] = atoi(limit)
+			}
+		}
+	}
+}
+
+// withDefault returns s with the field of the token name reset from def
+func (s ServerInfo) withDefault(name string, def ServerInfo) ServerInfo {
+	switch name {
+	case "NETWORK":
+		s.Network = def.Network
+	case "CASEMAPPING":
+		s.CaseMapping = def.CaseMapping
+	case "CHANTYPES":
+		s.ChanTypes = def.ChanTypes
+	case "PREFIX":
+		s.PrefixModes, s.PrefixSymbols = def.PrefixModes, def.PrefixSymbols
+	case "CHANMODES":
+		s.ChanModes = def.ChanModes
+	case "STATUSMSG":
+		s.StatusMsg = def.StatusMsg
+	case "MODES":
+		s.Modes = def.Modes
+	case "NICKLEN":
+		s.NickLen = def.NickLen
+	case "CHANNELLEN":
+		s.ChannelLen = def.ChannelLen
+	case "TOPICLEN":
+		s.TopicLen = def.TopicLen
+	case "KICKLEN":
+		s.KickLen = def.KickLen
+	case "AWAYLEN":
+		s.AwayLen = def.AwayLen
+	case "TARGMAX":
+		s.TargMax = make(map[string]int)
+	}
+	return s
+}
+
+// ServerInfo returns the features advertised by the server
+func (c *Client) ServerInfo() ServerInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.info.clone()
+}
+
+// Handle RPL_ISUPPORT
+func (c *Client) HandleRPL_ISUPPORT(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the first arg is our nick and the trailing one "are supported by
+	// this server"
+	if len(msg.Args) < 2 {
+		return nil
+	}
+
+	casemap := c.info.CaseMapping
+	for _, token := range msg.Args[1 : len(msg.Args)-1] {
+		name, value, _ := strings.Cut(token, "=")
+		c.info.apply(name, unescapeISupport(value))
+	}
+
+	for _, ch := range c.channels {
+		ch.casemap = c.info.CaseMapping
+		ch.prefixModes = c.info.PrefixModes
+	}
+	if c.info.CaseMapping != casemap {
+		c.refoldState()
+	}
+	c.logger.Debug("server features", "tokens", msg.Args[1:len(msg.Args)-1])
+	return nil
+}
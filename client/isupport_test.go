@@ -0,0 +1,112 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+func TestServerInfoApply(t *testing.T) {
+	client, _ := newTestClient(t, DefaultConfig())
+
+	receive(t, client,
+		":irc.test 005 gral AWAYLEN=200 CASEMAPPING=ascii CHANLIMIT=#:100 CHANMODES=beI,k,l,imnpst CHANNELLEN=64 CHANTYPES=# :are supported by this server",
+		":irc.test 005 gral KICKLEN=255 MODES=4 NETWORK=Test\\x20Net NICKLEN=30 PREFIX=(qaohv)~&@%+ STATUSMSG=~&@%+ :are supported by this server",
+		":irc.test 005 gral TARGMAX=NAMES:1,PRIVMSG:4,JOIN: TOPICLEN=307 WHOX :are supported by this server",
+	)
+
+	info := client.ServerInfo()
+	assert.Equal(t, "Test Net", info.Network)
+	assert.Equal(t, irc.CaseMappingASCII, info.CaseMapping)
+	assert.Equal(t, "#", info.ChanTypes)
+	assert.Equal(t, "qaohv", info.PrefixModes)
+	assert.Equal(t, "~&@%+", info.PrefixSymbols)
+	assert.Equal(t, ChanModes{List: "beI", Param: "k", SetParam: "l", Flag: "imnpst"}, info.ChanModes)
+	assert.Equal(t, "~&@%+", info.StatusMsg)
+	assert.Equal(t, 4, info.Modes)
+	assert.Equal(t, 30, info.NickLen)
+	assert.Equal(t, 64, info.ChannelLen)
+	assert.Equal(t, 307, info.TopicLen)
+	assert.Equal(t, 255, info.KickLen)
+	assert.Equal(t, 200, info.AwayLen)
+	assert.Equal(t, map[string]int{"NAMES": 1, "PRIVMSG": 4, "JOIN": 0}, info.TargMax)
+	assert.Equal(t, "#:100", info.Tokens["CHANLIMIT"])
+	assert.Contains(t, info.Tokens, "WHOX")
+
+	// the copy doesn't share the maps
+	info.TargMax["NAMES"] = 10
+	assert.Equal(t, 1, client.ServerInfo().TargMax["NAMES"])
+
+	receive(t, client, ":irc.test 005 gral -PREFIX -NETWORK -CASEMAPPING :are supported by this server")
+	info = client.ServerInfo()
+	assert.Equal(t, "ov", info.PrefixModes)
+	assert.Equal(t, "@+", info.PrefixSymbols)
+	assert.Equal(t, "", info.Network)
+	assert.Equal(t, irc.CaseMappingRFC1459, info.CaseMapping)
+	assert.NotContains(t, info.Tokens, "PREFIX")
+}
+
+func TestServerInfoHelpers(t *testing.T) {
+	info := DefaultServerInfo()
+	info.apply("CHANTYPES", "#&")
+	info.apply("STATUSMSG", "@+")
+	info.apply("PREFIX", "(ohv)@%+")
+
+	assert.True(t, info.IsChannel("#chan"))
+	assert.True(t, info.IsChannel("&local"))
+	assert.False(t, info.IsChannel("nick"))
+	assert.False(t, info.IsChannel(""))
+
+	status, channel := info.SplitStatusMsg("@+#chan")
+	assert.Equal(t, "@+", status)
+	assert.Equal(t, "#chan", channel)
+	status, channel = info.SplitStatusMsg("nick")
+	assert.Equal(t, "", status)
+	assert.Equal(t, "nick", channel)
+
+	symbols, nick := info.SplitPrefix("@%nick")
+	assert.Equal(t, "@%", symbols)
+	assert.Equal(t, "nick", nick)
+
+	mode, ok := info.PrefixMode('%')
+	assert.True(t, ok)
+	assert.Equal(t, byte('h'), mode)
+	_, ok = info.PrefixMode('~')
+	assert.False(t, ok)
+
+	assert.Equal(t, "a b=c\\", unescapeISupport(`a\x20b\x3Dc\`))
+	assert.Equal(t, `\xZZ`, unescapeISupport(`\xZZ`))
+}
+
+func TestClientUsesServerInfo(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	client, conn := newTestClient(t, cfg)
+
+	receive(t, client,
+		":irc.test 005 gral CHANTYPES=#! PREFIX=(qov)~@+ STATUSMSG=@+ :are supported by this server",
+		":gral!gral@host JOIN !chan",
+		":irc.test 332 gral !chan :bang topic",
+		":irc.test 353 gral = !chan :~owner @op +voice gral",
+		":irc.test 366 gral !chan :End of /NAMES list.",
+	)
+
+	ch, ok := client.Channel("!chan")
+	require.True(t, ok)
+	nicks := make([]string, 0)
+	for _, u := range ch.Users {
+		nicks = append(nicks, u.Nick)
+	}
+	assert.Equal(t, []string{"owner", "op", "voice", "gral"}, nicks)
+
+	conn.lines()
+	receive(t, client, ":op!o@host PRIVMSG @!chan :!topic")
+	assert.Equal(t, []string{"PRIVMSG @!chan :Topic: bang topic"}, conn.lines())
+
+	// & is not a channel on this server, nor a message for us
+	receive(t, client, ":op!o@host PRIVMSG &chan :!topic")
+	assert.Empty(t, conn.lines())
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+var ErrPingTimeout = errors.New("ping timeout")
+
+type KeepaliveConfig struct {
+	// Interval is how long the connection may stay idle before we PING
+	// the server, 0 disables the keepalive
+	Interval Duration `yaml:"interval" json:"interval" toml:"interval"`
+	// Timeout is how long to wait for an answer before dropping the
+	// connection
+	Timeout Duration `yaml:"timeout" json:"timeout" toml:"timeout"`
+}
+
+func (k KeepaliveConfig) Enabled() bool {
+	return k.Interval > 0
+}
+
+// pingState tracks our PINGs, guarded by Client.pingMu
+type pingState struct {
+	// lastRead is when the server last sent a line
+	lastRead time.Time
+	// token and sent identify the PING waiting for its PONG, token is
+	// empty when none is
+	token string
+	sent  time.Time
+	// lag is the round trip of the last answered PING
+	lag time.Duration
+}
+
+// touch records that the server sent a line
+func (c *Client) touch() {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	c.ping.lastRead = time.Now()
+}
+
+// Lag returns the round trip time to the server, it grows while a PING
+// waits for its answer
+func (c *Client) Lag() time.Duration {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+
+	if c.ping.token != "" {
+		return max(c.ping.lag, time.Since(c.ping.sent))
+	}
+	return c.ping.lag
+}
+
+// keepalive pings the server when the connection is idle until done is
+// closed, it returns ErrPingTimeout when the server stops answering
+func (c *Client) keepalive(done <-chan struct{}) error {
+	cfg := c.cfg.Keepalive
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	// PING is only allowed once registered
+	select {
+	case <-c.Registered():
+	case <-done:
+		return nil
+	}
+
+	interval, timeout := time.Duration(cfg.Interval), time.Duration(cfg.Timeout)
+	ticker := time.NewTicker(max(min(interval, timeout)/4, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case now := <-ticker.C:
+			if err := c.checkKeepalive(now, interval, timeout); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// checkKeepalive sends a PING when the connection was idle for interval
+// and fails when the last one is unanswered after timeout, even if other
+// lines arrived meanwhile
+func (c *Client) checkKeepalive(now time.Time, interval, timeout time.Duration) error {
+	c.pingMu.Lock()
+
+	switch {
+	case c.ping.token != "":
+		if now.Sub(c.ping.sent) >= timeout {
+			c.pingMu.Unlock()
+			return ErrPingTimeout
+		}
+		c.pingMu.Unlock()
+		return nil
+	case now.Sub(c.ping.lastRead) < interval:
+		c.pingMu.Unlock()
+		return nil
+	}
+
+	token := "gral-" + strconv.FormatInt(now.UnixNano(), 36)
+	c.ping.token, c.ping.sent = token, now
+	c.pingMu.Unlock()
+
+	if err := c.SendMsg(irc.NewMsg("PING", token)); err != nil {
+		c.logger.Error("error sending ping", "error", err)
+	}
+	return nil
+}
+
+// Handle PONG
+func (c *Client) HandlePONG(msg irc.Msg) error {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+
+	// server :token
+	if len(msg.Args) == 0 || c.ping.token == "" || msg.Args[len(msg.Args)-1] != c.ping.token {
+		return nil
+	}
+
+	c.ping.lag = time.Since(c.ping.sent)
+	c.ping.token = ""
+	c.logger.Debug("lag", "lag", c.ping.lag)
+	return nil
+}
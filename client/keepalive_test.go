@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// idle moves the keepalive state back in time as if the connection had
+// been idle for d
+func idle(c *Client, d time.Duration) {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	c.ping.lastRead = c.ping.lastRead.Add(-d)
+	c.ping.sent = c.ping.sent.Add(-d)
+}
+
+func TestCheckKeepalive(t *testing.T) {
+	cfg := DefaultConfig()
+	client, conn := newTestClient(t, cfg)
+
+	interval, timeout := time.Minute, 30*time.Second
+
+	// not idle long enough
+	idle(client, 30*time.Second)
+	require.NoError(t, client.checkKeepalive(time.Now(), interval, timeout))
+	assert.Empty(t, conn.lines())
+
+	idle(client, 30*time.Second)
+	require.NoError(t, client.checkKeepalive(time.Now(), interval, timeout))
+	lines := conn.lines()
+	require.Len(t, lines, 1)
+	token, ok := strings.CutPrefix(lines[0], "PING ")
+	require.True(t, ok)
+
+	// a single PING waits for its answer, the lag grows meanwhile
+	idle(client, 10*time.Second)
+	require.NoError(t, client.checkKeepalive(time.Now(), interval, timeout))
+	assert.Empty(t, conn.lines())
+	assert.GreaterOrEqual(t, client.Lag(), 10*time.Second)
+
+	// a PONG without our token changes nothing
+	receive(t, client, ":irc.test PONG irc.test :other")
+	idle(client, 20*time.Second)
+	assert.ErrorIs(t, client.checkKeepalive(time.Now(), interval, timeout), ErrPingTimeout)
+
+	receive(t, client, ":irc.test PONG irc.test :"+token)
+	require.NoError(t, client.checkKeepalive(time.Now(), interval, timeout))
+	lag := client.Lag()
+	assert.GreaterOrEqual(t, lag, 30*time.Second)
+	assert.Equal(t, lag, client.Lag())
+}
+
+func TestCheckKeepaliveTraffic(t *testing.T) {
+	cfg := DefaultConfig()
+	client, conn := newTestClient(t, cfg)
+
+	idle(client, time.Minute)
+	require.NoError(t, client.checkKeepalive(time.Now(), time.Minute, time.Second))
+	assert.Len(t, conn.lines(), 1)
+
+	// other lines don't stand for the missing PONG
+	time.Sleep(time.Millisecond)
+	client.touch()
+	assert.NoError(t, client.checkKeepalive(time.Now(), time.Minute, time.Second))
+	idle(client, time.Second)
+	client.touch()
+	assert.ErrorIs(t, client.checkKeepalive(time.Now(), time.Minute, time.Second), ErrPingTimeout)
+}
+
+func TestSupervisorPingTimeout(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	cfg.Keepalive = KeepaliveConfig{Interval: Duration(20 * time.Millisecond), Timeout: Duration(20 * time.Millisecond)}
+	cfg.Flood = FloodConfig{}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := NewClient(logger, cfg)
+
+	servers := make(chan net.Conn)
+	dial := func(ctx context.Context) (net.Conn, error) {
+		clientConn, serverConn := net.Pipe()
+		select {
+		case servers <- serverConn:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return clientConn, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	supervisor := NewSupervisor(client, dial, Backoff{Min: time.Millisecond, Max: time.Millisecond, Factor: 2}, logger)
+	welcomed := make(chan bool, 1)
+	done := make(chan error)
+	go func() {
+		ok, err := supervisor.serve(ctx)
+		welcomed <- ok
+		done <- err
+	}()
+
+	// welcome the client, then stop answering
+	conn := <-servers
+	go func() { _, _ = io.Copy(io.Discard, conn) }()
+	_, err := conn.Write([]byte(":irc.test 001 gral :Welcome\r\n"))
+	require.NoError(t, err)
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrPingTimeout)
+		assert.True(t, <-welcomed)
+	case <-ctx.Done():
+		t.Fatal("connection not dropped")
+	}
+}
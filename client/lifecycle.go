@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+var ErrRegistrationTimeout = errors.New("registration timed out")
+
+// ConnState is where the connection stands in its lifecycle
+type ConnState int
+
+const (
+	// StateDisconnected is the state without a connection
+	StateDisconnected ConnState = iota
+	// StateConnecting is the state of a new connection before registering
+	StateConnecting
+	// StateNegotiating is the state while the capabilities, SASL and
+	// NICK/USER are negotiated
+	StateNegotiating
+	// StateRegistered is the state once the server welcomed us, the
+	// connection is usable
+	StateRegistered
+	// StateClosing is the state once we sent QUIT
+	StateClosing
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateNegotiating:
+		return "negotiating"
+	case StateRegistered:
+		return "registered"
+	case StateClosing:
+		return "closing"
+	}
+	return "unknown"
+}
+
+// State returns the state of the connection
+func (c *Client) State() ConnState {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.state
+}
+
+// setState moves the connection to a new state, closing the Registered
+// channel on registration. A new connection only replaces the channel once
+// it was closed, so callers waiting since before the connection still get
+// woken up.
+func (c *Client) setState(state ConnState) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	if c.state == state {
+		return
+	}
+
+	closed := false
+	select {
+	case <-c.registered:
+		closed = true
+	default:
+	}
+
+	switch {
+	case state == StateConnecting && closed:
+		c.registered = make(chan struct{})
+	case state == StateRegistered && !closed:
+		close(c.registered)
+	}
+
+	c.logger.Debug("connection state", "from", c.state, "to", state)
+	c.state = state
+}
+
+// isRegistered reports whether the server welcomed us and we didn't quit
+func (c *Client) isRegistered() bool {
+	return c.State() == StateRegistered
+}
+
+// Registered returns a channel closed once the server welcomed us on the
+// current connection
+func (c *Client) Registered() <-chan struct{} {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.registered
+}
+
+// WaitRegistered blocks until the server welcomed us or ctx is done
+func (c *Client) WaitRegistered(ctx context.Context) error {
+	select {
+	case <-c.Registered():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Handle RPL_YOURHOST
+func (c *Client) HandleRPL_YOURHOST(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Your host is irc.example.net, running version ircd-1.0
+	text := strings.TrimPrefix(msg.Trailing, "Your host is ")
+	if name, version, ok := strings.Cut(text, ", running version "); ok {
+		c.info.ServerName = name
+		c.info.ServerVersion = version
+	}
+	return nil
+}
+
+// Handle RPL_CREATED
+func (c *Client) HandleRPL_CREATED(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.info.Created = strings.TrimPrefix(msg.Trailing, "This server was created ")
+	return nil
+}
+
+// Handle RPL_MYINFO
+func (c *Client) HandleRPL_MYINFO(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// me irc.example.net ircd-1.0 iowB biklmnopstv
+	if len(msg.Args) < 5 {
+		return nil
+	}
+	c.info.ServerName = msg.Args[1]
+	c.info.ServerVersion = msg.Args[2]
+	c.info.AvailableUserModes = msg.Args[3]
+	c.info.AvailableChanModes = msg.Args[4]
+	return nil
+}
+
+// Handle ERROR, the server tells why it closes the link
+func (c *Client) HandleERROR(msg irc.Msg) error {
+	c.logger.Warn("server closed the link", "reason", msg.Trailing)
+	return nil
+}
+
+// quit leaves the server gracefully: it flushes the queued lines, sends
+// QUIT and waits for the server to close the connection, closed, until
+// the shutdown timeout before closing conn itself
+func (c *Client) quit(conn net.Conn, closed <-chan struct{}) {
+	defer conn.Close()
+
+	if state := c.State(); state != StateNegotiating && state != StateRegistered {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cfg.ShutdownTimeout))
+	defer cancel()
+
+	// a server that stopped reading must not block us
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetWriteDeadline(deadline)
+
+	if err := c.Drain(ctx); err != nil {
+		c.logger.Warn("error flushing pending lines", "error", err)
+	}
+	if err := c.SendQUIT(c.cfg.QuitMessage); err != nil {
+		c.logger.Error("error quitting", "error", err)
+		return
+	}
+
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		c.logger.Warn("server didn't close the connection in time")
+	}
+}
+
+// Run connects to the server and serves the connection, reconnecting when
+// it is lost, until ctx is done. It then quits gracefully and returns nil.
+func (c *Client) Run(ctx context.Context) error {
+	supervisor := NewSupervisor(c, c.dial, c.cfg.Reconnect.Backoff(), c.logger)
+	err := supervisor.Run(ctx)
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+func TestRegistration(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	cfg.User = "gral"
+	client, conn := newTestClient(t, cfg)
+
+	events := make([]string, 0)
+	client.On(EventRegistered, func(irc.Msg) error {
+		// accessors may be used from the handlers
+		events = append(events, client.Me().Nick)
+		return nil
+	})
+
+	assert.Equal(t, StateConnecting, client.State())
+	require.NoError(t, client.Register())
+	assert.Equal(t, StateNegotiating, client.State())
+	conn.lines()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, client.WaitRegistered(ctx), context.DeadlineExceeded)
+
+	receive(t, client,
+		":irc.test 001 gral :Welcome to the Test Network gral!~gral@host",
+		":irc.test 002 gral :Your host is irc.test, running version ircd-1.2",
+		":irc.test 003 gral :This server was created Mon Jan 1 2024",
+		":irc.test 004 gral irc.test ircd-1.2 iowB biklmnopstv bklov",
+	)
+
+	select {
+	case <-client.Registered():
+	default:
+		t.Fatal("registered channel not closed")
+	}
+	require.NoError(t, client.WaitRegistered(context.Background()))
+	assert.Equal(t, StateRegistered, client.State())
+	assert.Equal(t, irc.UserIdentity{Nick: "gral", User: "~gral", Host: "host"}, client.Me())
+	assert.Len(t, events, 1)
+
+	info := client.ServerInfo()
+	assert.Equal(t, "irc.test", info.ServerName)
+	assert.Equal(t, "ircd-1.2", info.ServerVersion)
+	assert.Equal(t, "Mon Jan 1 2024", info.Created)
+	assert.Equal(t, "iowB", info.AvailableUserModes)
+	assert.Equal(t, "biklmnopstv", info.AvailableChanModes)
+
+	require.NoError(t, client.SendQUIT("bye"))
+	assert.Equal(t, StateClosing, client.State())
+
+	client.detach()
+	assert.Equal(t, StateDisconnected, client.State())
+
+	// a new connection waits for its own welcome
+	client.attach(conn)
+	select {
+	case <-client.Registered():
+		t.Fatal("registered channel closed before the welcome")
+	default:
+	}
+}
+
+func TestRegistrationConfirmsNick(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	client, _ := newTestClient(t, cfg)
+
+	// the server may cut or change the nick we asked for, the welcome text
+	// may not end with our prefix
+	receive(t, client, ":irc.test 001 gra :Welcome to the network")
+	assert.Equal(t, irc.UserIdentity{Nick: "gra"}, client.Me())
+}
+
+func TestWaitRegisteredBeforeRun(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := NewClient(logger, cfg)
+
+	servers := make(chan net.Conn)
+	client.dial = func(ctx context.Context) (net.Conn, error) {
+		clientConn, serverConn := net.Pipe()
+		select {
+		case servers <- serverConn:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return clientConn, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// waiting before the client is even connected
+	waited := make(chan error)
+	go func() { waited <- client.WaitRegistered(ctx) }()
+	registered := client.Registered()
+
+	done := make(chan error)
+	go func() { done <- client.Run(ctx) }()
+
+	conn := <-servers
+	go func() { _, _ = io.Copy(io.Discard, conn) }()
+	_, err := conn.Write([]byte(":irc.test 001 gral :Welcome\r\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, <-waited)
+	select {
+	case <-registered:
+	case <-ctx.Done():
+		t.Fatal("registered channel not closed")
+	}
+
+	cancel()
+	conn.Close()
+	assert.NoError(t, <-done)
+}
+
+func TestRunQuitsGracefully(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	cfg.User = "gral"
+	cfg.QuitMessage = "see you"
+	cfg.Flood = FloodConfig{Burst: 1, Delay: Duration(20 * time.Millisecond)}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := NewClient(logger, cfg)
+
+	servers := make(chan net.Conn)
+	client.dial = func(ctx context.Context) (net.Conn, error) {
+		clientConn, serverConn := net.Pipe()
+		select {
+		case servers <- serverConn:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return clientConn, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- client.Run(ctx) }()
+
+	conn := <-servers
+	lines := bufio.NewScanner(conn)
+	for i := 0; i < 3; i++ {
+		// CAP LS, NICK and USER
+		require.True(t, lines.Scan())
+	}
+	go func() { _, _ = conn.Write([]byte(":irc.test 001 gral :Welcome\r\n")) }()
+	<-client.Registered()
+
+	// queued lines are flushed before the QUIT
+	for _, text := range []string{"one", "two", "three"} {
+		require.NoError(t, client.SendPRIVMSG("#chan", text))
+	}
+	cancel()
+
+	got := make([]string, 0)
+	for lines.Scan() {
+		got = append(got, lines.Text())
+		if strings.HasPrefix(lines.Text(), "QUIT") {
+			break
+		}
+	}
+	assert.Equal(t, []string{
+		"PRIVMSG #chan :one",
+		"PRIVMSG #chan :two",
+		"PRIVMSG #chan :three",
+		"QUIT :see you",
+	}, got)
+
+	_, err := conn.Write([]byte("ERROR :Closing Link: gral (Quit: see you)\r\n"))
+	require.NoError(t, err)
+	conn.Close()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Run didn't return")
+	}
+}
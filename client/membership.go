@@ -0,0 +1,150 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// Member is a user in a channel with its membership modes
+type Member struct {
+	irc.UserIdentity
+	// Modes are the prefix modes of the user such as "ov", from the highest
+	Modes string
+}
+
+// ModeChange is one mode set or unset by a MODE message
+type ModeChange struct {
+	Add   bool
+	Mode  byte
+	Param string
+}
+
+// ParseModeChanges splits a mode string such as "+ov-k nick nick key" into
+// changes, taking the parameters of the modes that have one according to
+// PREFIX and CHANMODES
+func ParseModeChanges(info ServerInfo, modes string, params []string) []ModeChange {
+	changes := make([]ModeChange, 0, len(modes))
+	add := true
+
+	for i := 0; i < len(modes); i++ {
+		mode := modes[i]
+		switch mode {
+		case '+':
+			add = true
+			continue
+		case '-':
+			add = false
+			continue
+		}
+
+		change := ModeChange{Add: add, Mode: mode}
+		if info.modeTakesParam(mode, add) && len(params) > 0 {
+			change.Param = params[0]
+			params = params[1:]
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// modeTakesParam reports whether a channel mode comes with a parameter
+func (s ServerInfo) modeTakesParam(mode byte, add bool) bool {
+	switch {
+	case strings.IndexByte(s.PrefixModes, mode) >= 0,
+		strings.IndexByte(s.ChanModes.List, mode) >= 0,
+		strings.IndexByte(s.ChanModes.Param, mode) >= 0:
+		return true
+	case strings.IndexByte(s.ChanModes.SetParam, mode) >= 0:
+		return add
+	}
+	return false
+}
+
+// sortPrefixModes orders modes from the highest as in PREFIX
+func sortPrefixModes(prefixModes, modes string) string {
+	var b strings.Builder
+	for i := 0; i < len(prefixModes); i++ {
+		if strings.IndexByte(modes, prefixModes[i]) >= 0 {
+			b.WriteByte(prefixModes[i])
+		}
+	}
+	return b.String()
+}
+
+// member finds a user of the channel
+func (c *Channel) member(nick string) (*Member, bool) {
+	for _, u := range c.Users {
+		if c.casemap.Equal(u.Nick, nick) {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+// setPrefixMode gives or takes a membership mode such as 'o'
+func (c *Channel) setPrefixMode(nick string, mode byte, add bool) {
+	u, ok := c.member(nick)
+	if !ok {
+		return
+	}
+	if add {
+		u.Modes = sortPrefixModes(c.prefixModes, u.Modes+string(mode))
+	} else {
+		u.Modes = strings.ReplaceAll(u.Modes, string(mode), "")
+	}
+}
+
+// hasRank reports whether the member has mode or a higher one
+func hasRank(prefixModes string, m Member, mode byte) bool {
+	rank := strings.IndexByte(prefixModes, mode)
+	if rank < 0 {
+		return strings.IndexByte(m.Modes, mode) >= 0
+	}
+	for i := 0; i <= rank; i++ {
+		if strings.IndexByte(m.Modes, prefixModes[i]) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Member returns a user of the channel
+func (s ChannelSnapshot) Member(nick string) (Member, bool) {
+	for _, u := range s.Users {
+		if s.casemap.Equal(u.Nick, nick) {
+			return u, true
+		}
+	}
+	return Member{}, false
+}
+
+// HasRank reports whether nick has the membership mode or a higher one,
+// such as an owner for 'o'
+func (s ChannelSnapshot) HasRank(nick string, mode byte) bool {
+	m, ok := s.Member(nick)
+	return ok && hasRank(s.prefixModes, m, mode)
+}
+
+// IsOp reports whether nick is a channel operator or above
+func (s ChannelSnapshot) IsOp(nick string) bool {
+	return s.HasRank(nick, 'o')
+}
+
+// IsHalfOp reports whether nick is a half operator or above
+func (s ChannelSnapshot) IsHalfOp(nick string) bool {
+	return s.HasRank(nick, 'h') || s.IsOp(nick)
+}
+
+// IsVoice reports whether nick is voiced or above
+func (s ChannelSnapshot) IsVoice(nick string) bool {
+	return s.HasRank(nick, 'v')
+}
+
+// IsOp reports whether nick is a channel operator or above, the client
+// lock must be held
+func (c *Channel) IsOp(nick string) bool {
+	m, ok := c.member(nick)
+	return ok && hasRank(c.prefixModes, *m, 'o')
+}
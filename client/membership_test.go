@@ -0,0 +1,159 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseModeChanges(t *testing.T) {
+	info := DefaultServerInfo()
+	info.apply("PREFIX", "(qaohv)~&@%+")
+	info.apply("CHANMODES", "beI,k,l,imnpst")
+
+	assert.Equal(t, []ModeChange{
+		{Add: true, Mode: 'o', Param: "alice"},
+		{Add: true, Mode: 'v', Param: "bob"},
+		{Add: false, Mode: 'k', Param: "key"},
+		{Add: false, Mode: 'l'},
+		{Add: true, Mode: 'b', Param: "*!*@host"},
+		{Add: true, Mode: 'm'},
+		{Add: true, Mode: 'l', Param: "10"},
+	}, ParseModeChanges(info, "+ov-kl+bml", []string{"alice", "bob", "key", "*!*@host", "10"}))
+
+	// a missing parameter is left empty
+	assert.Equal(t, []ModeChange{{Add: true, Mode: 'k'}}, ParseModeChanges(info, "+k", nil))
+}
+
+func TestMembershipPrefixes(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	client, _ := newTestClient(t, cfg)
+
+	receive(t, client,
+		":irc.test 005 gral PREFIX=(qaohv)~&@%+ CHANMODES=beI,k,l,imnpst :are supported by this server",
+		":gral!gral@host JOIN #chan",
+		":irc.test 353 gral = #chan :gral ~@owner @op %half +voice user",
+		":irc.test 366 gral #chan :End of /NAMES list.",
+		":new!n@host JOIN #chan",
+	)
+
+	ch, ok := client.Channel("#chan")
+	require.True(t, ok)
+
+	owner, ok := ch.Member("OWNER")
+	require.True(t, ok)
+	assert.Equal(t, "qo", owner.Modes)
+
+	assert.True(t, ch.IsOp("owner"))
+	assert.True(t, ch.IsOp("op"))
+	assert.False(t, ch.IsOp("half"))
+	assert.True(t, ch.IsHalfOp("half"))
+	assert.True(t, ch.IsHalfOp("op"))
+	assert.False(t, ch.IsHalfOp("voice"))
+	assert.True(t, ch.IsVoice("voice"))
+	assert.True(t, ch.IsVoice("op"))
+	assert.False(t, ch.IsVoice("user"))
+	assert.False(t, ch.IsVoice("new"))
+	assert.False(t, ch.IsOp("unknown"))
+
+	receive(t, client,
+		":op!o@host MODE #chan +o-o+v user op new",
+		":op!o@host MODE #chan +k-o+l secret owner 10",
+		":user!u@host NICK user2",
+	)
+
+	ch, _ = client.Channel("#chan")
+	assert.True(t, ch.IsOp("user2"))
+	assert.False(t, ch.IsOp("op"))
+	assert.True(t, ch.IsVoice("new"))
+	owner, _ = ch.Member("owner")
+	assert.Equal(t, "q", owner.Modes)
+	assert.True(t, ch.IsOp("owner"))
+}
+
+func TestRequireOp(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	client, conn := newTestClient(t, cfg)
+
+	require.NoError(t, client.Router().Register(BotCommand{
+		Name:       "secret",
+		Permission: RequireOp,
+		Run:        func(ctx *CommandContext) error { return ctx.Reply("ok") },
+	}))
+
+	receive(t, client,
+		":gral!gral@host JOIN #chan",
+		":irc.test 353 gral = #chan :gral @op user",
+		":irc.test 366 gral #chan :End of /NAMES list.",
+	)
+	conn.lines()
+
+	receive(t, client, ":op!o@host PRIVMSG #chan :!secret")
+	assert.Equal(t, []string{"PRIVMSG #chan :ok"}, conn.lines())
+
+	receive(t, client, ":user!u@host PRIVMSG #chan :!secret")
+	assert.Equal(t, []string{"NOTICE user :You are not allowed to use !secret"}, conn.lines())
+
+	receive(t, client, ":op!o@host PRIVMSG gral :secret")
+	assert.Equal(t, []string{"NOTICE op :You are not allowed to use !secret"}, conn.lines())
+}
+
+func TestRejoinOnlyJoinedChannels(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	cfg.Channels = nil
+	client, conn := newTestClient(t, cfg)
+
+	receive(t, client,
+		":gral!gral@host JOIN #a",
+		// some servers send the channel as the trailing param
+		":gral!gral@host JOIN :#b",
+		":gral!gral@host JOIN #kicked",
+		":gral!gral@host JOIN #parted",
+		// replies about channels we are not in
+		":irc.test 353 gral = #other :someone",
+		":irc.test 366 gral #other :End of /NAMES list.",
+		":irc.test 332 gral #other :other topic",
+		":stranger!s@host JOIN #elsewhere",
+		":op!o@host TOPIC #a :hello world",
+		":op!o@host KICK #kicked gral :bye",
+		":gral!gral@host PART #parted",
+	)
+	assert.Equal(t, "hello world", client.channels["#a"].Topic)
+	assert.Equal(t, "op", client.channels["#a"].TopicChangeBy)
+	assert.Len(t, client.channels, 2)
+
+	client.detach()
+	client.attach(conn)
+	conn.lines()
+
+	receive(t, client, ":irc.test 376 gral :End of MOTD")
+	assert.ElementsMatch(t, []string{"JOIN #a", "JOIN #b"}, conn.lines())
+}
+
+func TestRejoinWithCurrentKey(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	cfg.Channels = nil
+	client, conn := newTestClient(t, cfg)
+
+	require.NoError(t, client.JoinKey("#a", "old"))
+	require.NoError(t, client.JoinKey("#b", "kept"))
+	receive(t, client,
+		":gral!gral@host JOIN #a",
+		":gral!gral@host JOIN #b",
+		":op!o@host MODE #a +k new",
+		// a key hidden from us keeps the one we joined with
+		":irc.test 324 gral #b +k *",
+	)
+
+	client.detach()
+	client.attach(conn)
+	conn.lines()
+
+	receive(t, client, ":irc.test 376 gral :End of MOTD")
+	assert.ElementsMatch(t, []string{"JOIN #a new", "JOIN #b kept"}, conn.lines())
+}
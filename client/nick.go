@@ -0,0 +1,124 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// maxNickAttempts bounds the nicks tried at registration before giving up
+const maxNickAttempts = 10
+
+var ErrNoNick = errors.New("no nickname available")
+
+// nickCandidate returns the nick to try after attempt refused ones: the
+// primary nick, the alternates, then the primary nick with a "_", "_2",
+// "_3"... suffix cut to nickLen when known
+func nickCandidate(primary string, alts []string, attempt, nickLen int) string {
+	if attempt == 0 {
+		return primary
+	}
+	if attempt <= len(alts) {
+		return alts[attempt-1]
+	}
+
+	suffix := "_"
+	if n := attempt - len(alts); n > 1 {
+		suffix += strconv.Itoa(n)
+	}
+
+	base := primary
+	if nickLen > len(suffix) && len(base)+len(suffix) > nickLen {
+		base = base[:nickLen-len(suffix)]
+	}
+	return base + suffix
+}
+
+// HandleNickError handles ERR_ERRONEUSNICKNAME, ERR_NICKNAMEINUSE,
+// ERR_NICKCOLLISION and ERR_UNAVAILRESOURCE. During registration the next
+// candidate nick is tried, later the nick change is just refused.
+func (c *Client) HandleNickError(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// * nick :Nickname is already in use
+	if len(msg.Args) < 2 {
+		return nil
+	}
+	nick := msg.Args[1]
+	if c.info.IsChannel(nick) {
+		// ERR_UNAVAILRESOURCE for a channel
+		return nil
+	}
+
+	if c.isRegistered() {
+		c.logger.Warn("nick change refused", "nick", nick, "reason", msg.Trailing)
+		if c.info.CaseMapping.Equal(nick, c.cfg.Nick) {
+			c.scheduleRegain()
+		}
+		return nil
+	}
+
+	c.nickAttempt++
+	if c.nickAttempt >= maxNickAttempts {
+		_ = c.SendQUIT("no nickname available")
+		return fmt.Errorf("%w after %d attempts", ErrNoNick, c.nickAttempt)
+	}
+
+	next := nickCandidate(c.cfg.Nick, c.cfg.AltNicks, c.nickAttempt, c.info.NickLen)
+	c.logger.Warn("nick refused, trying another one", "nick", nick, "next", next, "reason", msg.Trailing)
+	c.me.Nick = next
+	return c.Nick(next)
+}
+
+// scheduleRegain retries the primary nick every RegainInterval until we
+// get it back, the client lock must be held
+func (c *Client) scheduleRegain() {
+	if c.cfg.RegainInterval <= 0 || c.regainTimer != nil {
+		return
+	}
+	c.regainTimer = time.AfterFunc(time.Duration(c.cfg.RegainInterval), c.regain)
+}
+
+// stopRegain stops retrying the primary nick, the client lock must be held
+func (c *Client) stopRegain() {
+	if c.regainTimer != nil {
+		c.regainTimer.Stop()
+		c.regainTimer = nil
+	}
+}
+
+func (c *Client) regain() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.regainTimer = nil
+	if c.regainNick() {
+		c.scheduleRegain()
+	}
+}
+
+// regainNick asks for the primary nick when we don't have it, it reports
+// whether it did, the client lock must be held
+func (c *Client) regainNick() bool {
+	if !c.isRegistered() || c.info.CaseMapping.Equal(c.me.Nick, c.cfg.Nick) {
+		return false
+	}
+
+	c.logger.Info("regaining nick", "nick", c.cfg.Nick)
+	if err := c.Nick(c.cfg.Nick); err != nil {
+		c.logger.Error("error regaining nick", "error", err)
+	}
+	return true
+}
+
+// nickReleased takes the primary nick back as soon as its holder leaves
+// it, the client lock must be held
+func (c *Client) nickReleased(nick string) {
+	if c.info.CaseMapping.Equal(nick, c.cfg.Nick) {
+		c.regainNick()
+	}
+}
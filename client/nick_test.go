@@ -0,0 +1,109 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+func TestNickCandidate(t *testing.T) {
+	alts := []string{"gral2", "gral3"}
+
+	tests := []struct {
+		attempt, nickLen int
+		want             string
+	}{
+		{0, 0, "gral"},
+		{1, 0, "gral2"},
+		{2, 0, "gral3"},
+		{3, 0, "gral_"},
+		{4, 0, "gral_2"},
+		{12, 0, "gral_10"},
+		{4, 5, "gra_2"},
+		// too short to cut
+		{4, 2, "gral_2"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, nickCandidate("gral", alts, tt.attempt, tt.nickLen), "attempt %d", tt.attempt)
+	}
+}
+
+func TestNickInUseAtRegistration(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	cfg.AltNicks = []string{"gral2"}
+	client, conn := newTestClient(t, cfg)
+
+	receive(t, client, ":irc.test 433 * gral :Nickname is already in use")
+	assert.Equal(t, []string{"NICK gral2"}, conn.lines())
+
+	receive(t, client, ":irc.test 432 * gral2 :Erroneous nickname")
+	assert.Equal(t, []string{"NICK gral_"}, conn.lines())
+
+	// a channel is not a nick
+	receive(t, client, ":irc.test 437 * #chan :Channel is temporarily unavailable")
+	assert.Empty(t, conn.lines())
+
+	receive(t, client, ":irc.test 001 gral_ :Welcome gral_!gral@host")
+	assert.Equal(t, "gral_", client.Me().Nick)
+
+	// the holder of our nick leaves it
+	receive(t, client, ":gral!other@host NICK someone")
+	assert.Equal(t, []string{"NICK gral"}, conn.lines())
+
+	// someone took it first, retry once it quits
+	receive(t, client, ":irc.test 433 gral_ gral :Nickname is already in use")
+	assert.Equal(t, "gral_", client.Me().Nick)
+	receive(t, client, ":gral!other@host QUIT :bye")
+	assert.Equal(t, []string{"NICK gral"}, conn.lines())
+
+	receive(t, client, ":gral_!gral@host NICK gral")
+	assert.Equal(t, "gral", client.Me().Nick)
+
+	// we have it, nothing to regain
+	receive(t, client, ":gral!other@host QUIT :bye")
+	assert.Empty(t, conn.lines())
+}
+
+func TestNickGiveUp(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	client, conn := newTestClient(t, cfg)
+
+	for i := 1; i < maxNickAttempts; i++ {
+		receive(t, client, ":irc.test 433 * x :Nickname is already in use")
+	}
+	conn.lines()
+
+	msg, err := irc.ParseMessage(":irc.test 433 * x :Nickname is already in use")
+	require.NoError(t, err)
+	assert.ErrorIs(t, client.Handle(*msg), ErrNoNick)
+	assert.Equal(t, []string{"QUIT :no nickname available"}, conn.lines())
+}
+
+func TestNickRegainLoop(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	cfg.RegainInterval = Duration(10 * time.Millisecond)
+	client, conn := newTestClient(t, cfg)
+
+	receive(t, client,
+		":irc.test 433 * gral :Nickname is already in use",
+		":irc.test 001 gral_ :Welcome gral_!gral@host",
+	)
+	conn.lines()
+
+	assert.Eventually(t, func() bool {
+		return len(conn.lines()) > 0
+	}, time.Second, 5*time.Millisecond)
+
+	receive(t, client, ":gral_!gral@host NICK gral")
+	client.mu.RLock()
+	assert.Nil(t, client.regainTimer)
+	client.mu.RUnlock()
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Query is a private conversation with a user
+type Query struct {
+	Nick     string
+	Messages []string
+	// LastActive is when the user last sent a private message
+	LastActive time.Time
+}
+
+func NewQuery(nick string) *Query {
+	return &Query{Nick: nick, Messages: make([]string, 0)}
+}
+
+func (q *Query) AddMessage(msg string) {
+	q.Messages = append(q.Messages, msg)
+	q.LastActive = time.Now()
+}
+
+// snapshot copies the query, the client lock must be held
+func (q *Query) snapshot() Query {
+	s := *q
+	s.Messages = slices.Clone(q.Messages)
+	return s
+}
+
+// query returns the conversation with nick, opening it if needed, the
+// client lock must be held
+func (c *Client) query(nick string) *Query {
+	key := c.fold(nick)
+	q, ok := c.queries[key]
+	if !ok {
+		q = NewQuery(nick)
+		c.queries[key] = q
+		c.logger.Debug("query opened", "nick", nick)
+	}
+	return q
+}
+
+// Query returns a copy of the private conversation with nick, if any
+func (c *Client) Query(nick string) (Query, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	q, ok := c.queries[c.fold(nick)]
+	if !ok {
+		return Query{}, false
+	}
+	return q.snapshot(), true
+}
+
+// Queries returns copies of the open private conversations sorted by nick
+func (c *Client) Queries() []Query {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	queries := make([]Query, 0, len(c.queries))
+	for _, q := range c.queries {
+		queries = append(queries, q.snapshot())
+	}
+	slices.SortFunc(queries, func(a, b Query) int {
+		return strings.Compare(c.fold(a.Nick), c.fold(b.Nick))
+	})
+	return queries
+}
+
+// CloseQuery forgets the private conversation with nick
+func (c *Client) CloseQuery(nick string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeQuery(nick)
+}
+
+func (c *Client) closeQuery(nick string) {
+	delete(c.queries, c.fold(nick))
+}
+
+// renameQuery follows a user changing nick, the client lock must be held
+func (c *Client) renameQuery(oldNick, newNick string) {
+	q, ok := c.queries[c.fold(oldNick)]
+	if !ok {
+		return
+	}
+	delete(c.queries, c.fold(oldNick))
+	q.Nick = newNick
+	c.queries[c.fold(newNick)] = q
+}
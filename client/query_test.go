@@ -0,0 +1,39 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueries(t *testing.T) {
+	client, _ := newTestClient(t, DefaultConfig())
+
+	receive(t, client,
+		":Bob!b@host PRIVMSG [bot]Gral-irc :hello",
+		":alice!a@host PRIVMSG [bot]gral-IRC :hi",
+		":bob!b@host PRIVMSG [bot]Gral-irc :still there?",
+	)
+
+	queries := client.Queries()
+	require.Len(t, queries, 2)
+	assert.Equal(t, "alice", queries[0].Nick)
+	assert.Equal(t, "Bob", queries[1].Nick)
+	assert.Len(t, queries[1].Messages, 2)
+	assert.False(t, queries[1].LastActive.IsZero())
+
+	receive(t, client, ":bob!b@host NICK robert")
+	_, ok := client.Query("bob")
+	assert.False(t, ok)
+	q, ok := client.Query("ROBERT")
+	require.True(t, ok)
+	assert.Equal(t, "robert", q.Nick)
+
+	receive(t, client, ":alice!a@host QUIT :bye")
+	_, ok = client.Query("alice")
+	assert.False(t, ok)
+
+	client.detach()
+	assert.Empty(t, client.Queries())
+}
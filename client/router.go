@@ -0,0 +1,579 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+var (
+	ErrDuplicateCommand = errors.New("command already registered")
+	ErrBadArguments     = errors.New("bad arguments")
+	ErrUnclosedQuote    = errors.New("unclosed quote")
+)
+
+// ArgumentError explains to the user why the arguments were rejected
+type ArgumentError struct {
+	Reason string
+}
+
+func (e *ArgumentError) Error() string {
+	return e.Reason
+}
+
+func (e *ArgumentError) Is(target error) bool {
+	return target == ErrBadArguments
+}
+
+func argError(format string, a ...any) error {
+	return &ArgumentError{Reason: fmt.Sprintf(format, a...)}
+}
+
+type ArgType int
+
+const (
+	ArgString ArgType = iota
+	ArgInt
+	// ArgBool flags take no value, positional ones accept true/false/yes/no
+	ArgBool
+)
+
+// ArgSpec describes a positional argument
+type ArgSpec struct {
+	Name     string
+	Type     ArgType
+	Optional bool
+	// Rest takes all the remaining words, it must be the last argument
+	Rest bool
+}
+
+// FlagSpec describes a --flag, given as --name, --name=value or --name value
+type FlagSpec struct {
+	Name string
+	Type ArgType
+	Help string
+}
+
+// Permission tells whether the sender may run the command
+type Permission func(ctx *CommandContext) bool
+
+// CommandScope tells where a command may be used
+type CommandScope int
+
+const (
+	ScopeAll CommandScope = iota
+	ScopeChannel
+	ScopePrivate
+)
+
+// Allows reports whether the command may run in a channel or in private
+func (s CommandScope) Allows(private bool) bool {
+	switch s {
+	case ScopeChannel:
+		return !private
+	case ScopePrivate:
+		return private
+	}
+	return true
+}
+
+type BotCommand struct {
+	Name    string
+	Aliases []string
+	// Help is a one line description shown by help
+	Help  string
+	Args  []ArgSpec
+	Flags []FlagSpec
+	// Permission is checked before running, everyone may run the command
+	// when nil
+	Permission Permission
+	// Cooldown is the minimum delay between two uses by the same user
+	Cooldown time.Duration
+	// Scope restricts the command to channels or private messages
+	Scope CommandScope
+	Run   func(ctx *CommandContext) error
+}
+
+// Usage returns the command syntax, such as "!kick <nick> [reason...]"
+func (b *BotCommand) Usage(prefix string) string {
+	parts := []string{prefix + b.Name}
+	for _, arg := range b.Args {
+		name := arg.Name
+		if arg.Rest {
+			name += "..."
+		}
+		if arg.Optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+	for _, flag := range b.Flags {
+		if flag.Type == ArgBool {
+			parts = append(parts, "[--"+flag.Name+"]")
+		} else {
+			parts = append(parts, "[--"+flag.Name+"=<"+flag.Name+">]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// CommandContext is given to a running command
+type CommandContext struct {
+	Client  *Client
+	Msg     irc.Msg
+	Command *BotCommand
+	// Sender is the user who sent the command
+	Sender irc.UserIdentity
+	// Target is where replies go
+	Target string
+	// Channel is the channel the command was sent in, nil in private
+	Channel *ChannelSnapshot
+	// Query is the private conversation the command was sent in, nil in a
+	// channel
+	Query *Query
+
+	values map[string]any
+}
+
+// Reply answers in the target of the command
+func (ctx *CommandContext) Reply(message string) error {
+	return ctx.Client.SendPRIVMSG(ctx.Target, message)
+}
+
+// Private reports whether the command was sent in a private message
+func (ctx *CommandContext) Private() bool {
+	return ctx.Channel == nil
+}
+
+// Has reports whether an argument or flag was given
+func (ctx *CommandContext) Has(name string) bool {
+	_, ok := ctx.values[name]
+	return ok
+}
+
+func (ctx *CommandContext) String(name string) string {
+	v, _ := ctx.values[name].(string)
+	return v
+}
+
+func (ctx *CommandContext) Int(name string) int {
+	v, _ := ctx.values[name].(int)
+	return v
+}
+
+func (ctx *CommandContext) Bool(name string) bool {
+	v, _ := ctx.values[name].(bool)
+	return v
+}
+
+// CommandRouter parses PRIVMSG into bot commands, addressed with the
+// prefix ("!topic") or the bot nick ("gral: topic"). The prefix is optional
+// in private messages.
+type CommandRouter struct {
+	client *Client
+	prefix string
+
+	// mu guards the commands and cooldowns, commands may be registered
+	// from any goroutine
+	mu       sync.RWMutex
+	commands map[string]*BotCommand
+	// order keeps the registration order for help
+	order []*BotCommand
+
+	// cooldowns holds when each command may run again per nick, expired
+	// entries are pruned
+	cooldowns map[string]time.Time
+	now       func() time.Time
+}
+
+func NewCommandRouter(client *Client, prefix string) *CommandRouter {
+	return &CommandRouter{
+		client:    client,
+		prefix:    prefix,
+		commands:  make(map[string]*BotCommand),
+		cooldowns: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// Register adds a command, skipped when the config disables it
+func (r *CommandRouter) Register(cmd BotCommand) error {
+	if !r.client.cfg.CommandEnabled(cmd.Name) {
+		return nil
+	}
+
+	if masks, ok := r.client.cfg.Permissions[cmd.Name]; ok {
+		cmd.Permission = AllowMasks(masks...)
+	}
+
+	names := append([]string{cmd.Name}, cmd.Aliases...)
+
+	for i, arg := range cmd.Args {
+		if arg.Rest && i != len(cmd.Args)-1 {
+			return fmt.Errorf("%s: rest argument %s must be last: %w", cmd.Name, arg.Name, ErrBadArguments)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range names {
+		if _, ok := r.commands[strings.ToLower(name)]; ok {
+			return fmt.Errorf("%s: %w", name, ErrDuplicateCommand)
+		}
+	}
+
+	c := &cmd
+	for _, name := range names {
+		r.commands[strings.ToLower(name)] = c
+	}
+	r.order = append(r.order, c)
+
+	return nil
+}
+
+// Lookup finds a command by name or alias
+func (r *CommandRouter) Lookup(name string) (*BotCommand, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cmd, ok := r.commands[strings.ToLower(name)]
+	return cmd, ok
+}
+
+// Commands returns the commands usable in a channel or in private, in
+// registration order
+func (r *CommandRouter) Commands(private bool) []*BotCommand {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cmds := make([]*BotCommand, 0, len(r.order))
+	for _, cmd := range r.order {
+		if cmd.Scope.Allows(private) {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+// strip removes the prefix or the bot nick addressing the command
+func (r *CommandRouter) strip(text string, private bool) (string, bool) {
+	if r.prefix != "" {
+		if rest, ok := strings.CutPrefix(text, r.prefix); ok {
+			return rest, true
+		}
+	}
+	if private {
+		return text, true
+	}
+
+	nick := r.client.Me().Nick
+	if nick == "" || len(text) <= len(nick) || !r.client.CaseMapping().Equal(text[:len(nick)], nick) {
+		return "", false
+	}
+	rest := text[len(nick):]
+	if rest[0] != ':' && rest[0] != ',' {
+		return "", false
+	}
+	return strings.TrimLeft(rest[1:], " "), true
+}
+
+// Handle runs the command in a PRIVMSG, if any, replying to target. A nil
+// channel means the message was sent in private by target.
+func (r *CommandRouter) Handle(msg irc.Msg, target string, channel *ChannelSnapshot, query *Query) error {
+	private := channel == nil
+
+	// CTCP requests are not commands
+	if strings.HasPrefix(msg.Trailing, "\x01") {
+		return nil
+	}
+
+	text, ok := r.strip(msg.Trailing, private)
+	if !ok {
+		return nil
+	}
+
+	words, err := splitArgs(text)
+	if errors.Is(err, ErrUnclosedQuote) {
+		if fields := strings.Fields(text); len(fields) > 0 {
+			if cmd, ok := r.Lookup(fields[0]); ok {
+				return r.client.SendPRIVMSG(target, "Unclosed quote, usage: "+cmd.Usage(r.prefix))
+			}
+		}
+		return nil
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	cmd, ok := r.Lookup(words[0])
+	if !ok {
+		// replies to unknown commands are notices so bots don't answer back
+		if private {
+			return r.client.SendNOTICE(msg.Nick, fmt.Sprintf(
+				"Unknown command %s, try %shelp", words[0], r.prefix,
+			))
+		}
+		return nil
+	}
+
+	if !cmd.Scope.Allows(private) {
+		where := "in a channel"
+		if cmd.Scope == ScopePrivate {
+			where = "in a private message"
+		}
+		return r.client.SendNOTICE(msg.Nick, fmt.Sprintf("%s%s only works %s", r.prefix, cmd.Name, where))
+	}
+
+	ctx := &CommandContext{
+		Client:  r.client,
+		Msg:     msg,
+		Command: cmd,
+		Sender:  irc.UserIdentity{Nick: msg.Nick, User: msg.User, Host: msg.Host},
+		Target:  target,
+		Channel: channel,
+		Query:   query,
+	}
+
+	if cmd.Permission != nil && !cmd.Permission(ctx) {
+		r.client.logger.Info("command denied", "command", cmd.Name, "from", msg.Prefix)
+		return r.client.SendNOTICE(msg.Nick, "You are not allowed to use "+r.prefix+cmd.Name)
+	}
+
+	ctx.values, err = parseArgs(cmd, words[1:])
+	if err != nil {
+		return ctx.Reply(fmt.Sprintf("%s, usage: %s", capitalize(err.Error()), cmd.Usage(r.prefix)))
+	}
+
+	if wait := r.cooldown(cmd, msg.Nick); wait > 0 {
+		return r.client.SendNOTICE(msg.Nick, fmt.Sprintf(
+			"%s%s is on cooldown, retry in %s", r.prefix, cmd.Name, wait.Round(time.Second),
+		))
+	}
+
+	if err := cmd.Run(ctx); err != nil {
+		return fmt.Errorf("error running command %s: %w", cmd.Name, err)
+	}
+	return nil
+}
+
+// cooldown returns how long nick must wait before using cmd again, or
+// records the use when it may run now
+func (r *CommandRouter) cooldown(cmd *BotCommand, nick string) time.Duration {
+	if cmd.Cooldown <= 0 {
+		return 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := cmd.Name + "\x00" + r.client.CaseMapping().Fold(nick)
+	now := r.now()
+	if until, ok := r.cooldowns[key]; ok && now.Before(until) {
+		return until.Sub(now)
+	}
+
+	for k, until := range r.cooldowns {
+		if !now.Before(until) {
+			delete(r.cooldowns, k)
+		}
+	}
+	r.cooldowns[key] = now.Add(cmd.Cooldown)
+	return 0
+}
+
+// splitArgs splits words on spaces, keeping quoted strings together. A
+// backslash escapes the next character inside double quotes.
+func splitArgs(in string) ([]string, error) {
+	words := make([]string, 0)
+	var word strings.Builder
+	inWord := false
+	quote := rune(0)
+	escaped := false
+
+	for _, r := range in {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, ErrUnclosedQuote
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+func parseValue(typ ArgType, name, value string) (any, error) {
+	switch typ {
+	case ArgInt:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, argError("%s must be a number", name)
+		}
+		return v, nil
+	case ArgBool:
+		switch strings.ToLower(value) {
+		case "true", "yes", "on", "1":
+			return true, nil
+		case "false", "no", "off", "0":
+			return false, nil
+		}
+		return nil, argError("%s must be yes or no", name)
+	}
+	return value, nil
+}
+
+// parseArgs matches words with the flags and arguments of the command
+func parseArgs(cmd *BotCommand, words []string) (map[string]any, error) {
+	values := make(map[string]any)
+	positional := make([]string, 0, len(words))
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if word == "--" {
+			positional = append(positional, words[i+1:]...)
+			break
+		}
+
+		name, ok := strings.CutPrefix(word, "--")
+		if !ok || name == "" {
+			positional = append(positional, word)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(name, "=")
+		fi := slices.IndexFunc(cmd.Flags, func(f FlagSpec) bool { return f.Name == name })
+		if fi == -1 {
+			return nil, argError("unknown flag --%s", name)
+		}
+		flag := cmd.Flags[fi]
+
+		if !hasValue {
+			if flag.Type == ArgBool {
+				values[name] = true
+				continue
+			}
+			if i+1 >= len(words) {
+				return nil, argError("--%s needs a value", name)
+			}
+			i++
+			value = words[i]
+		}
+
+		v, err := parseValue(flag.Type, "--"+name, value)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = v
+	}
+
+	for _, arg := range cmd.Args {
+		if len(positional) == 0 {
+			if !arg.Optional {
+				return nil, argError("missing %s", arg.Name)
+			}
+			continue
+		}
+
+		value := positional[0]
+		positional = positional[1:]
+		if arg.Rest {
+			value = strings.Join(append([]string{value}, positional...), " ")
+			positional = nil
+		}
+
+		v, err := parseValue(arg.Type, arg.Name, value)
+		if err != nil {
+			return nil, err
+		}
+		values[arg.Name] = v
+	}
+
+	if len(positional) > 0 {
+		return nil, argError("too many arguments")
+	}
+
+	return values, nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// AllowMasks allows senders matching one of the nick!user@host masks,
+// where * matches any run of characters and ? a single one
+func AllowMasks(masks ...string) Permission {
+	return func(ctx *CommandContext) bool {
+		hostmask := ctx.Sender.Nick + "!" + ctx.Sender.User + "@" + ctx.Sender.Host
+		for _, mask := range masks {
+			if matchMask(strings.ToLower(mask), strings.ToLower(hostmask)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RequireOp allows the operators of the channel the command was sent in
+func RequireOp(ctx *CommandContext) bool {
+	return ctx.Channel != nil && ctx.Channel.IsOp(ctx.Sender.Nick)
+}
+
+func matchMask(mask, s string) bool {
+	// star and pos remember the last * to backtrack to
+	star, pos := -1, 0
+	i, j := 0, 0
+	for j < len(s) {
+		switch {
+		case i < len(mask) && (mask[i] == '?' || mask[i] == s[j]):
+			i++
+			j++
+		case i < len(mask) && mask[i] == '*':
+			star, pos = i, j
+			i++
+		case star != -1:
+			pos++
+			i, j = star+1, pos
+		default:
+			return false
+		}
+	}
+	for i < len(mask) && mask[i] == '*' {
+		i++
+	}
+	return i == len(mask)
+}
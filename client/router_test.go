@@ -0,0 +1,190 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitArgs(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    []string
+		wantErr error
+	}{
+		{in: "a b  c", want: []string{"a", "b", "c"}},
+		{in: `say "hello world" 'single quoted'`, want: []string{"say", "hello world", "single quoted"}},
+		{in: `"escaped \" quote"`, want: []string{`escaped " quote`}},
+		{in: `pre"fix suf"fix`, want: []string{"prefix suffix"}},
+		{in: `""`, want: []string{""}},
+		{in: `"open`, wantErr: ErrUnclosedQuote},
+	}
+
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			words, err := splitArgs(c.in)
+			if c.wantErr != nil {
+				assert.ErrorIs(t, err, c.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.want, words)
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	cmd := &BotCommand{
+		Name: "kick",
+		Args: []ArgSpec{
+			{Name: "nick"},
+			{Name: "count", Type: ArgInt, Optional: true},
+			{Name: "reason", Optional: true, Rest: true},
+		},
+		Flags: []FlagSpec{
+			{Name: "ban", Type: ArgBool},
+			{Name: "duration", Type: ArgInt},
+		},
+	}
+	assert.Equal(t, "!kick <nick> [count] [reason...] [--ban] [--duration=<duration>]", cmd.Usage("!"))
+
+	values, err := parseArgs(cmd, []string{"bob", "--ban", "3", "--duration", "10", "go", "away"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"nick": "bob", "count": 3, "reason": "go away", "ban": true, "duration": 10}, values)
+
+	values, err = parseArgs(cmd, []string{"bob", "--duration=5", "--", "7", "--ban"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"nick": "bob", "count": 7, "reason": "--ban", "duration": 5}, values)
+
+	for _, words := range [][]string{
+		{},
+		{"bob", "many"},
+		{"bob", "--nope"},
+		{"bob", "--duration"},
+		{"bob", "--duration=x"},
+	} {
+		_, err := parseArgs(cmd, words)
+		assert.ErrorIs(t, err, ErrBadArguments, words)
+	}
+
+	_, err = parseArgs(&BotCommand{Name: "noargs"}, []string{"extra"})
+	assert.ErrorIs(t, err, ErrBadArguments)
+}
+
+func TestMatchMask(t *testing.T) {
+	assert.True(t, matchMask("*!*@host", "nick!user@host"))
+	assert.True(t, matchMask("n?ck!*@*", "nick!user@host"))
+	assert.True(t, matchMask("*", ""))
+	assert.True(t, matchMask("[bot]*!*@*", "[bot]gral!u@h"))
+	assert.False(t, matchMask("*!*@other", "nick!user@host"))
+	assert.False(t, matchMask("nick", "nick!user@host"))
+}
+
+func TestCommandRouter(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	cfg.Permissions = map[string][]string{"users": {"*!*@trusted"}}
+	client, conn := newTestClient(t, cfg)
+
+	now := time.Unix(0, 0)
+	client.router.now = func() time.Time { return now }
+
+	require.NoError(t, client.Router().Register(BotCommand{
+		Name:     "echo",
+		Aliases:  []string{"say"},
+		Help:     "repeat the text",
+		Args:     []ArgSpec{{Name: "text", Rest: true}},
+		Cooldown: 10 * time.Second,
+		Run: func(ctx *CommandContext) error {
+			return ctx.Reply(ctx.Sender.Nick + " said " + ctx.String("text"))
+		},
+	}))
+	assert.ErrorIs(t, client.Router().Register(BotCommand{Name: "SAY"}), ErrDuplicateCommand)
+
+	receive(t, client, ":gral!gral@host JOIN #chan", ":irc.test 332 gral #chan :the topic")
+	conn.lines()
+
+	say := func(from, text string) []string {
+		receive(t, client, ":"+from+" PRIVMSG #chan :"+text)
+		return conn.lines()
+	}
+
+	assert.Equal(t, []string{"PRIVMSG #chan :Topic: the topic"}, say("bob!b@host", "!topic"))
+	assert.Equal(t, []string{"PRIVMSG #chan :Topic: the topic"}, say("bob!b@host", "gral: topic"))
+	assert.Equal(t, []string{"PRIVMSG #chan :Topic: the topic"}, say("bob!b@host", "GRAL, TOPIC"))
+	assert.Empty(t, say("bob!b@host", "topic"))
+	assert.Empty(t, say("bob!b@host", "!unknown"))
+
+	assert.Equal(t, []string{`PRIVMSG #chan :bob said hello "world"`}, say("bob!b@host", `!say hello '"world"'`))
+	assert.Equal(t, []string{"NOTICE bob :!echo is on cooldown, retry in 10s"}, say("bob!b@host", "!echo again"))
+	now = now.Add(11 * time.Second)
+	assert.Equal(t, []string{"PRIVMSG #chan :bob said again"}, say("bob!b@host", "!echo again"))
+	// only commands with a cooldown are tracked, expired uses are pruned
+	assert.Len(t, client.router.cooldowns, 1)
+	now = now.Add(11 * time.Second)
+	say("alice!a@host", "!echo hi")
+	assert.Len(t, client.router.cooldowns, 1)
+
+	assert.Equal(t, []string{"PRIVMSG #chan :Missing text, usage: !echo <text...>"}, say("alice!a@host", "!echo"))
+	assert.Equal(t, []string{"PRIVMSG #chan :Unclosed quote, usage: !echo <text...>"}, say("alice!a@host", `!echo "oops`))
+
+	assert.Equal(t, []string{"NOTICE bob :You are not allowed to use !users"}, say("bob!b@host", "!users"))
+	assert.Len(t, say("op!o@trusted", "!names"), 1)
+
+	assert.Equal(t, []string{"PRIVMSG #chan :Commands: !help, !topic, !users, !echo - !help <command> for details"}, say("bob!b@host", "!help"))
+	assert.Equal(t, []string{"PRIVMSG #chan :!echo <text...> - repeat the text (aliases: say)"}, say("bob!b@host", "!help !say"))
+}
+
+func TestCommandRouterDisabledCommands(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Commands = []string{"topic"}
+	client, conn := newTestClient(t, cfg)
+
+	receive(t, client, ":[bot]Gral-irc!u@h JOIN #chan")
+	conn.lines()
+
+	receive(t, client, ":bob!b@h PRIVMSG #chan :!users", ":bob!b@h PRIVMSG #chan :!help")
+	assert.Empty(t, conn.lines())
+
+	receive(t, client, ":bob!b@h PRIVMSG #chan :!topic")
+	assert.Equal(t, []string{"PRIVMSG #chan :Topic: "}, conn.lines())
+}
+
+func TestCommandRouterPrivate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	client, conn := newTestClient(t, cfg)
+
+	require.NoError(t, client.Router().Register(BotCommand{
+		Name:  "secret",
+		Scope: ScopePrivate,
+		Run: func(ctx *CommandContext) error {
+			require.NotNil(t, ctx.Query)
+			return ctx.Reply("psst " + ctx.Query.Nick)
+		},
+	}))
+
+	receive(t, client, ":gral!gral@host JOIN #chan")
+	conn.lines()
+
+	pm := func(text string) []string {
+		receive(t, client, ":bob!b@host PRIVMSG gral :"+text)
+		return conn.lines()
+	}
+
+	assert.Equal(t, []string{"PRIVMSG bob :psst bob"}, pm("secret"))
+	assert.Equal(t, []string{"PRIVMSG bob :psst bob"}, pm("!secret"))
+	assert.Equal(t, []string{"NOTICE bob :!topic only works in a channel"}, pm("topic"))
+	assert.Equal(t, []string{"NOTICE bob :Unknown command nope, try !help"}, pm("nope"))
+	assert.Equal(t, []string{"PRIVMSG bob :Commands: !help, !secret - !help <command> for details"}, pm("help"))
+	assert.Empty(t, pm("\x01VERSION\x01"))
+
+	receive(t, client, ":bob!b@host PRIVMSG #chan :!secret")
+	assert.Equal(t, []string{"NOTICE bob :!secret only works in a private message"}, conn.lines())
+
+	// messages to someone else are not for us
+	receive(t, client, ":bob!b@host PRIVMSG other :help")
+	assert.Empty(t, conn.lines())
+}
@@ -0,0 +1,376 @@
+package client
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+var (
+	ErrSASLFailed      = errors.New("sasl authentication failed")
+	ErrSASLUnavailable = errors.New("sasl not available")
+	ErrUnknownMech     = errors.New("unknown sasl mechanism")
+	ErrBadChallenge    = errors.New("invalid sasl challenge")
+)
+
+// AUTHENTICATE payloads are sent in base64 chunks of at most this size
+const saslChunkSize = 400
+
+const (
+	SASLPlain       = "PLAIN"
+	SASLExternal    = "EXTERNAL"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+)
+
+type SASLConfig struct {
+	// Mechanism is PLAIN, EXTERNAL or SCRAM-SHA-256, SASL is disabled when empty
+	Mechanism string `yaml:"mechanism" json:"mechanism" toml:"mechanism"`
+	Username  string `yaml:"username" json:"username" toml:"username"`
+	Password  string `yaml:"password" json:"password" toml:"password"`
+	// Required aborts the connection when authentication fails instead of
+	// continuing unauthenticated
+	Required bool `yaml:"required" json:"required" toml:"required"`
+}
+
+type SASLMechanism interface {
+	Name() string
+	// Next returns the response to a server challenge, the first
+	// challenge is empty
+	Next(challenge []byte) ([]byte, error)
+}
+
+func NewSASLMechanism(cfg SASLConfig) (SASLMechanism, error) {
+	switch strings.ToUpper(cfg.Mechanism) {
+	case SASLPlain:
+		return &saslPlain{user: cfg.Username, password: cfg.Password}, nil
+	case SASLExternal:
+		return &saslExternal{}, nil
+	case SASLScramSHA256:
+		return &saslScram{
+			name:     SASLScramSHA256,
+			hash:     sha256.New,
+			user:     cfg.Username,
+			password: cfg.Password,
+			nonce:    randomNonce,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%s: %w", cfg.Mechanism, ErrUnknownMech)
+}
+
+type saslPlain struct {
+	user     string
+	password string
+}
+
+func (m *saslPlain) Name() string { return SASLPlain }
+
+func (m *saslPlain) Next([]byte) ([]byte, error) {
+	return []byte(m.user + "\x00" + m.user + "\x00" + m.password), nil
+}
+
+// saslExternal relies on the TLS client certificate
+type saslExternal struct{}
+
+func (m *saslExternal) Name() string { return SASLExternal }
+
+func (m *saslExternal) Next([]byte) ([]byte, error) {
+	return nil, nil
+}
+
+// saslScram implements RFC 5802 without channel binding
+type saslScram struct {
+	name     string
+	hash     func() hash.Hash
+	user     string
+	password string
+	nonce    func() string
+
+	step            int
+	clientNonce     string
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func (m *saslScram) Name() string { return m.name }
+
+func (m *saslScram) Next(challenge []byte) ([]byte, error) {
+	m.step++
+
+	switch m.step {
+	case 1:
+		user := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(m.user)
+		m.clientNonce = m.nonce()
+		m.clientFirstBare = "n=" + user + ",r=" + m.clientNonce
+		return []byte("n,," + m.clientFirstBare), nil
+
+	case 2:
+		return m.clientFinal(string(challenge))
+
+	case 3:
+		attrs := scramAttributes(string(challenge))
+		if e, ok := attrs["e"]; ok {
+			return nil, fmt.Errorf("server error %s: %w", e, ErrSASLFailed)
+		}
+
+		signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+		if err != nil || !hmac.Equal(signature, m.serverSignature) {
+			return nil, fmt.Errorf("server signature mismatch: %w", ErrBadChallenge)
+		}
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unexpected challenge: %w", ErrBadChallenge)
+}
+
+func (m *saslScram) clientFinal(serverFirst string) ([]byte, error) {
+	attrs := scramAttributes(serverFirst)
+
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, m.clientNonce) || len(nonce) == len(m.clientNonce) {
+		return nil, fmt.Errorf("bad nonce: %w", ErrBadChallenge)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return nil, fmt.Errorf("bad salt: %w", ErrBadChallenge)
+	}
+
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations < 1 {
+		return nil, fmt.Errorf("bad iteration count: %w", ErrBadChallenge)
+	}
+
+	salted := pbkdf2(m.hash, []byte(m.password), salt, iterations, m.hash().Size())
+	clientKey := m.hmac(salted, []byte("Client Key"))
+	h := m.hash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	withoutProof := "c=biws,r=" + nonce
+	authMessage := []byte(m.clientFirstBare + "," + serverFirst + "," + withoutProof)
+
+	proof := m.hmac(storedKey, authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+
+	m.serverSignature = m.hmac(m.hmac(salted, []byte("Server Key")), authMessage)
+
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+func (m *saslScram) hmac(key, data []byte) []byte {
+	mac := hmac.New(m.hash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func scramAttributes(in string) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range strings.Split(in, ",") {
+		if k, v, ok := strings.Cut(attr, "="); ok {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+// pbkdf2 derives a key as described in RFC 8018
+func pbkdf2(h func() hash.Hash, password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(h, password)
+	key := make([]byte, 0, keyLen)
+
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+
+		t := bytes.Clone(u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
+
+func randomNonce() string {
+	b := make([]byte, 18)
+	_, _ = rand.Read(b)
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+// saslSession is an authentication in progress
+type saslSession struct {
+	mech SASLMechanism
+	// buf accumulates a challenge split across several AUTHENTICATE
+	buf strings.Builder
+}
+
+// startSASL begins the authentication once the sasl capability is enabled
+func (c *Client) startSASL() error {
+	mech, err := NewSASLMechanism(c.cfg.SASL)
+	if err != nil {
+		return err
+	}
+
+	c.sasl = &saslSession{mech: mech}
+	if err := c.SendMsg(irc.NewMsg("AUTHENTICATE", mech.Name())); err != nil {
+		return fmt.Errorf("error sending authenticate: %w", err)
+	}
+	return nil
+}
+
+// sendAuthenticate sends a response in base64 chunks
+func (c *Client) sendAuthenticate(response []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(response)
+
+	for {
+		chunk := encoded[:min(len(encoded), saslChunkSize)]
+		encoded = encoded[len(chunk):]
+		if chunk == "" {
+			chunk = "+"
+		}
+
+		if err := c.SendMsg(irc.NewMsg("AUTHENTICATE", chunk)); err != nil {
+			return fmt.Errorf("error sending authenticate: %w", err)
+		}
+
+		// a full last chunk is followed by an empty one
+		if len(chunk) < saslChunkSize && encoded == "" {
+			return nil
+		}
+	}
+}
+
+// saslDone ends the authentication, err tells whether it failed
+func (c *Client) saslDone(err error) error {
+	c.sasl = nil
+
+	if err != nil {
+		if c.cfg.SASL.Required {
+			c.logger.Error("sasl authentication failed, aborting", "error", err)
+			c.fatal = fmt.Errorf("%w: %w", ErrPermanent, err)
+			_ = c.SendQUIT("SASL authentication failed")
+			return c.fatal
+		}
+		c.logger.Warn("sasl authentication failed, continuing unauthenticated", "error", err)
+	}
+
+	return c.maybeEndCaps()
+}
+
+// Handle AUTHENTICATE
+func (c *Client) HandleAUTHENTICATE(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sasl == nil || len(msg.Args) == 0 {
+		return nil
+	}
+
+	chunk := msg.Args[0]
+	if chunk != "+" {
+		c.sasl.buf.WriteString(chunk)
+	}
+	if len(chunk) == saslChunkSize {
+		return nil
+	}
+
+	challenge, err := base64.StdEncoding.DecodeString(c.sasl.buf.String())
+	c.sasl.buf.Reset()
+	if err != nil {
+		return c.abortSASL(fmt.Errorf("%w: %w", ErrBadChallenge, err))
+	}
+
+	response, err := c.sasl.mech.Next(challenge)
+	if err != nil {
+		return c.abortSASL(err)
+	}
+
+	return c.sendAuthenticate(response)
+}
+
+// abortSASL cancels the exchange, the server answers with ERR_SASLABORTED
+func (c *Client) abortSASL(err error) error {
+	c.logger.Error("aborting sasl authentication", "error", err)
+	if err := c.SendMsg(irc.NewMsg("AUTHENTICATE", "*")); err != nil {
+		return fmt.Errorf("error sending authenticate: %w", err)
+	}
+	return nil
+}
+
+// Handle RPL_LOGGEDIN
+func (c *Client) HandleRPL_LOGGEDIN(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(msg.Args) > 2 {
+		c.account = msg.Args[2]
+	}
+	c.logger.Info("logged in", "account", c.account)
+	return nil
+}
+
+// Handle RPL_LOGGEDOUT
+func (c *Client) HandleRPL_LOGGEDOUT(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.account = ""
+	c.logger.Info("logged out")
+	return nil
+}
+
+// Handle RPL_SASLSUCCESS
+func (c *Client) HandleRPL_SASLSUCCESS(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.saslDone(nil)
+}
+
+// Handle ERR_SASLFAIL, ERR_SASLTOOLONG, ERR_SASLABORTED, ERR_NICKLOCKED
+// and ERR_SASLALREADY
+func (c *Client) HandleSASLFailure(msg irc.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sasl == nil {
+		return nil
+	}
+	return c.saslDone(fmt.Errorf("%s %s: %w", msg.CommandName(), msg.Trailing, ErrSASLFailed))
+}
+
+// Handle RPL_SASLMECHS
+func (c *Client) HandleRPL_SASLMECHS(msg irc.Msg) error {
+	if len(msg.Args) > 1 {
+		c.logger.Info("sasl mechanisms supported by the server", "mechanisms", msg.Args[1])
+	}
+	return nil
+}
+
+// Account returns the account we are logged in as, if any
+func (c *Client) Account() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.account
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// test vector from RFC 7677
+func TestSASLScramSHA256(t *testing.T) {
+	mech, err := NewSASLMechanism(SASLConfig{Mechanism: "scram-sha-256", Username: "user", Password: "pencil"})
+	require.NoError(t, err)
+	mech.(*saslScram).nonce = func() string { return "rOprNGfwEbeRWgbNEkqO" }
+
+	first, err := mech.Next(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "n,,n=user,r=rOprNGfwEbeRWgbNEkqO", string(first))
+
+	final, err := mech.Next([]byte("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	require.NoError(t, err)
+	assert.Equal(t,
+		"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		string(final),
+	)
+
+	last, err := mech.Next([]byte("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="))
+	require.NoError(t, err)
+	assert.Empty(t, last)
+}
+
+func TestSASLScramBadServerSignature(t *testing.T) {
+	mech, err := NewSASLMechanism(SASLConfig{Mechanism: SASLScramSHA256, Username: "user", Password: "pencil"})
+	require.NoError(t, err)
+	mech.(*saslScram).nonce = func() string { return "abc" }
+
+	_, err = mech.Next(nil)
+	require.NoError(t, err)
+
+	_, err = mech.Next([]byte("r=xyz,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	assert.ErrorIs(t, err, ErrBadChallenge)
+}
+
+func saslConfig(sasl SASLConfig) Config {
+	cfg := DefaultConfig()
+	cfg.Caps = nil
+	cfg.SASL = sasl
+	return cfg
+}
+
+func TestSASLPlainChunking(t *testing.T) {
+	password := strings.Repeat("p", 600)
+	client, conn := newTestClient(t, saslConfig(SASLConfig{Mechanism: SASLPlain, Username: "gral", Password: password}))
+
+	require.NoError(t, client.SendCAPLS())
+	receive(t, client, ":irc.test CAP * LS :sasl=PLAIN,EXTERNAL")
+	receive(t, client, ":irc.test CAP * ACK :sasl")
+	assert.Equal(t, []string{"CAP LS 302", "CAP REQ :sasl", "AUTHENTICATE PLAIN"}, conn.lines())
+
+	receive(t, client, "AUTHENTICATE +")
+	lines := conn.lines()
+	require.Len(t, lines, 3)
+
+	encoded := ""
+	for _, line := range lines {
+		chunk := strings.TrimPrefix(line, "AUTHENTICATE ")
+		assert.LessOrEqual(t, len(chunk), saslChunkSize)
+		encoded += chunk
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "gral\x00gral\x00"+password, string(decoded))
+
+	receive(t, client,
+		":irc.test 900 gral gral!gral@host gral :You are now logged in as gral",
+		":irc.test 903 gral :SASL authentication successful",
+	)
+	assert.Equal(t, []string{"CAP END"}, conn.lines())
+	assert.Equal(t, "gral", client.Account())
+}
+
+func TestSASLFullLastChunk(t *testing.T) {
+	// 300 bytes encode to exactly 400 base64 characters
+	client, conn := newTestClient(t, DefaultConfig())
+	require.NoError(t, client.sendAuthenticate([]byte(strings.Repeat("x", 300))))
+
+	lines := conn.lines()
+	require.Len(t, lines, 2)
+	assert.Equal(t, "AUTHENTICATE +", lines[1])
+}
+
+func TestSASLFailurePolicy(t *testing.T) {
+	t.Run("continue unauthenticated", func(t *testing.T) {
+		client, conn := newTestClient(t, saslConfig(SASLConfig{Mechanism: SASLPlain, Username: "gral", Password: "bad"}))
+		require.NoError(t, client.SendCAPLS())
+		receive(t, client, ":irc.test CAP * LS :sasl", ":irc.test CAP * ACK :sasl", "AUTHENTICATE +")
+		conn.lines()
+
+		receive(t, client, ":irc.test 904 gral :SASL authentication failed")
+		assert.Equal(t, []string{"CAP END"}, conn.lines())
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		client, conn := newTestClient(t, saslConfig(SASLConfig{Mechanism: SASLPlain, Username: "gral", Password: "bad", Required: true}))
+		require.NoError(t, client.SendCAPLS())
+		receive(t, client, ":irc.test CAP * LS :sasl", ":irc.test CAP * ACK :sasl", "AUTHENTICATE +")
+		conn.lines()
+
+		msg, err := irc.ParseMessage(":irc.test 904 gral :SASL authentication failed")
+		require.NoError(t, err)
+		assert.ErrorIs(t, client.Handle(*msg), ErrSASLFailed)
+		assert.ErrorIs(t, client.fatalError(), ErrPermanent)
+		assert.Equal(t, []string{"QUIT :SASL authentication failed"}, conn.lines())
+	})
+
+	t.Run("server without sasl", func(t *testing.T) {
+		client, conn := newTestClient(t, saslConfig(SASLConfig{Mechanism: SASLPlain, Username: "gral", Password: "pw"}))
+		require.NoError(t, client.SendCAPLS())
+		receive(t, client, ":irc.test CAP * LS :multi-prefix")
+		assert.Equal(t, []string{"CAP LS 302", "CAP END"}, conn.lines())
+	})
+}
@@ -0,0 +1,239 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FloodConfig throttles the lines sent to the server so that the bot
+// doesn't get killed for flooding
+type FloodConfig struct {
+	// Burst is the number of lines sent at once before throttling
+	Burst int `yaml:"burst" json:"burst" toml:"burst"`
+	// Delay is the time to earn back one line, flood protection is disabled
+	// when zero
+	Delay Duration `yaml:"delay" json:"delay" toml:"delay"`
+	// PenaltyBytes makes a line cost one more line per PenaltyBytes bytes,
+	// long lines are not penalized when zero
+	PenaltyBytes int `yaml:"penalty_bytes" json:"penalty_bytes" toml:"penalty_bytes"`
+}
+
+// Enabled reports whether lines are throttled
+func (f FloodConfig) Enabled() bool {
+	return f.Delay > 0
+}
+
+// cost returns how many lines sending line counts for
+func (f FloodConfig) cost(line []byte) float64 {
+	if f.PenaltyBytes <= 0 {
+		return 1
+	}
+	return 1 + float64(len(line)/f.PenaltyBytes)
+}
+
+// TokenBucket allows Burst lines at once then one line every Delay
+type TokenBucket struct {
+	burst  float64
+	delay  time.Duration
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func NewTokenBucket(burst int, delay time.Duration) *TokenBucket {
+	return &TokenBucket{
+		burst:  float64(burst),
+		delay:  delay,
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+// Reserve takes cost tokens and returns how long to wait before using
+// them, the bucket goes into debt when it doesn't hold enough tokens
+func (b *TokenBucket) Reserve(cost float64) time.Duration {
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+float64(now.Sub(b.last))/float64(b.delay))
+	}
+	b.last = now
+
+	b.tokens -= cost
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens * float64(b.delay))
+}
+
+// SendQueue writes lines in order at the pace allowed by its token bucket
+type SendQueue struct {
+	write  func([]byte) (int, error)
+	onErr  func(error)
+	flood  FloodConfig
+	bucket *TokenBucket
+
+	mu    sync.Mutex
+	lines [][]byte
+	// busy is true while a line taken from lines is being sent
+	busy    bool
+	closed  bool
+	waiters []chan struct{}
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewSendQueue starts a queue writing with write, write errors are given
+// to onErr
+func NewSendQueue(flood FloodConfig, write func([]byte) (int, error), onErr func(error)) *SendQueue {
+	q := &SendQueue{
+		write:  write,
+		onErr:  onErr,
+		flood:  flood,
+		bucket: NewTokenBucket(flood.Burst, time.Duration(flood.Delay)),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Push queues a line, including its CRLF
+func (q *SendQueue) Push(line []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrNotConnected
+	}
+	q.lines = append(q.lines, line)
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Len returns the number of lines waiting to be sent
+func (q *SendQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.lines)
+}
+
+// Drain waits until every queued line is sent
+func (q *SendQueue) Drain(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrNotConnected
+	}
+	if len(q.lines) == 0 && !q.busy {
+		q.mu.Unlock()
+		return nil
+	}
+	waiter := make(chan struct{})
+	q.waiters = append(q.waiters, waiter)
+	q.mu.Unlock()
+
+	select {
+	case <-waiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.lines) > 0 || q.busy {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// Close stops the queue, dropping the lines not sent yet
+func (q *SendQueue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.stop)
+	q.mu.Unlock()
+
+	<-q.done
+}
+
+// next waits for a line to send, it returns false once the queue is closed
+func (q *SendQueue) next() ([]byte, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.lines) > 0 {
+			line := q.lines[0]
+			q.lines = q.lines[1:]
+			q.busy = true
+			q.mu.Unlock()
+			return line, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.wake:
+		case <-q.stop:
+			return nil, false
+		}
+	}
+}
+
+// sent wakes the Drain callers once the queue is empty
+func (q *SendQueue) sent() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.busy = false
+	if len(q.lines) > 0 {
+		return
+	}
+	for _, waiter := range q.waiters {
+		close(waiter)
+	}
+	q.waiters = nil
+}
+
+func (q *SendQueue) run() {
+	defer func() {
+		q.mu.Lock()
+		for _, waiter := range q.waiters {
+			close(waiter)
+		}
+		q.waiters = nil
+		q.mu.Unlock()
+		close(q.done)
+	}()
+
+	for {
+		line, ok := q.next()
+		if !ok {
+			return
+		}
+
+		if wait := q.bucket.Reserve(q.flood.cost(line)); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-q.stop:
+				timer.Stop()
+				return
+			}
+		}
+
+		if _, err := q.write(line); err != nil {
+			q.onErr(err)
+		}
+		q.sent()
+	}
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewTokenBucket(2, time.Second)
+	b.now = func() time.Time { return now }
+
+	assert.Equal(t, time.Duration(0), b.Reserve(1))
+	assert.Equal(t, time.Duration(0), b.Reserve(1))
+	assert.Equal(t, time.Second, b.Reserve(1))
+	assert.Equal(t, 2*time.Second, b.Reserve(1))
+
+	// the debt is paid back before earning tokens again
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, time.Second, b.Reserve(1))
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, time.Duration(0), b.Reserve(2))
+	assert.Equal(t, 1500*time.Millisecond, b.Reserve(1.5))
+}
+
+func TestFloodCost(t *testing.T) {
+	f := FloodConfig{PenaltyBytes: 100}
+	assert.Equal(t, 1.0, f.cost(make([]byte, 99)))
+	assert.Equal(t, 2.0, f.cost(make([]byte, 100)))
+	assert.Equal(t, 5.0, f.cost(make([]byte, 450)))
+	assert.Equal(t, 1.0, FloodConfig{}.cost(make([]byte, 450)))
+}
+
+func TestSendQueue(t *testing.T) {
+	written := make(chan string, 10)
+	write := func(line []byte) (int, error) {
+		written <- string(line)
+		return len(line), nil
+	}
+
+	delay := 50 * time.Millisecond
+	q := NewSendQueue(FloodConfig{Burst: 2, Delay: Duration(delay)}, write, func(err error) {
+		t.Error(err)
+	})
+	defer q.Close()
+
+	start := time.Now()
+	for _, line := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, q.Push([]byte(line)))
+	}
+
+	require.NoError(t, q.Drain(context.Background()))
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 2*delay)
+	assert.Less(t, elapsed, 10*delay)
+
+	close(written)
+	lines := make([]string, 0)
+	for line := range written {
+		lines = append(lines, line)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, lines)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestSendQueueClose(t *testing.T) {
+	q := NewSendQueue(FloodConfig{Burst: 1, Delay: Duration(time.Hour)}, func(line []byte) (int, error) {
+		return len(line), nil
+	}, func(error) {})
+
+	require.NoError(t, q.Push([]byte("a")))
+	require.NoError(t, q.Push([]byte("b")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Drain(ctx), context.DeadlineExceeded)
+
+	drained := make(chan error)
+	go func() { drained <- q.Drain(context.Background()) }()
+
+	q.Close()
+	assert.ErrorIs(t, <-drained, ErrNotConnected)
+	assert.ErrorIs(t, q.Push([]byte("c")), ErrNotConnected)
+}
+
+func TestClientSendQueue(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Flood = FloodConfig{Burst: 1, Delay: Duration(time.Hour)}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := NewClient(logger, cfg)
+
+	clientConn, serverConn := net.Pipe()
+	client.attach(clientConn)
+	defer client.detach()
+
+	lines := bufio.NewScanner(serverConn)
+	expectLine := func(want string) {
+		t.Helper()
+		require.True(t, lines.Scan())
+		assert.Equal(t, want, lines.Text())
+	}
+
+	require.NoError(t, client.SendPRIVMSG("#chan", "first"))
+	require.NoError(t, client.SendPRIVMSG("#chan", "second"))
+	expectLine("PRIVMSG #chan :first")
+
+	// PONG doesn't wait behind the throttled PRIVMSG
+	go func() { _ = client.HandlePing(irc.Msg{Command: "PING", Args: []string{"irc.test"}}) }()
+	expectLine("PONG irc.test")
+
+	// nor does our keepalive PING
+	go func() { _ = client.checkKeepalive(time.Now().Add(time.Hour), time.Minute, time.Minute) }()
+	require.True(t, lines.Scan())
+	assert.True(t, strings.HasPrefix(lines.Text(), "PING gral-"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, client.Drain(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// moreSuffix ends the last line of a message cut to MaxLines
+const moreSuffix = " …more"
+
+// the longest host name we may be seen with when the server didn't tell
+const maxHostLen = 63
+
+// formatCodeLen returns the length of the formatting code at s[i], or zero
+// when there is none. Colors are \x03 with up to two digits for the
+// foreground and optionally a comma and two digits for the background,
+// \x04 takes hex RRGGBB colors the same way.
+func formatCodeLen(s string, i int) int {
+	var isDigit func(byte) bool
+	var size int
+
+	switch s[i] {
+	case '\x03':
+		isDigit = func(b byte) bool { return b >= '0' && b <= '9' }
+		size = 2
+	case '\x04':
+		isDigit = func(b byte) bool {
+			return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+		}
+		size = 6
+	default:
+		return 0
+	}
+
+	digits := func(from int) int {
+		n := 0
+		for n < size && from+n < len(s) && isDigit(s[from+n]) {
+			n++
+		}
+		return n
+	}
+
+	n := 1
+	fg := digits(i + n)
+	if fg == 0 {
+		return n
+	}
+	n += fg
+
+	if i+n+1 < len(s) && s[i+n] == ',' {
+		if bg := digits(i + n + 1); bg > 0 {
+			n += 1 + bg
+		}
+	}
+	return n
+}
+
+// splitPoint returns where to cut s so that the first part holds at most
+// max bytes, at the last space when there is one, never inside a rune or
+// a formatting code
+func splitPoint(s string, max int) int {
+	i, lastSpace := 0, 0
+	for i < len(s) {
+		n := formatCodeLen(s, i)
+		if n == 0 {
+			_, n = utf8.DecodeRuneInString(s[i:])
+		}
+		if i+n > max {
+			break
+		}
+		i += n
+		if s[i-1] == ' ' {
+			lastSpace = i
+		}
+	}
+
+	switch {
+	case i < len(s) && s[i] == ' ':
+		return i
+	case lastSpace > 0:
+		return lastSpace
+	case i == 0:
+		// a code longer than max, cut it rather than looping forever
+		return min(max, len(s))
+	}
+	return i
+}
+
+// SplitMessage splits text into lines of at most max bytes, on line
+// breaks and between words when possible
+func SplitMessage(text string, max int) []string {
+	if max <= 0 {
+		return nil
+	}
+
+	lines := make([]string, 0, 1)
+	for _, paragraph := range strings.Split(text, "\n") {
+		paragraph = strings.TrimSuffix(paragraph, "\r")
+
+		for len(paragraph) > max {
+			cut := splitPoint(paragraph, max)
+			if line := strings.TrimRight(paragraph[:cut], " "); line != "" {
+				lines = append(lines, line)
+			}
+			paragraph = strings.TrimLeft(paragraph[cut:], " ")
+		}
+		if paragraph != "" {
+			lines = append(lines, paragraph)
+		}
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// limitLines keeps at most maxLines lines of max bytes, ending the last one
+// with moreSuffix when some text is left out
+func limitLines(lines []string, maxLines, max int) []string {
+	if maxLines <= 0 || len(lines) <= maxLines {
+		return lines
+	}
+	if max <= len(moreSuffix) {
+		return lines[:maxLines]
+	}
+
+	rest := strings.Join(lines[maxLines-1:], " ")
+	last := SplitMessage(rest, max-len(moreSuffix))[0]
+	return append(lines[:maxLines-1], last+moreSuffix)
+}
+
+// textBudget returns how many bytes of text fit in a command sent to target
+// once the server relays it with our nick!user@host prefix
+func (c *Client) textBudget(command, target string) int {
+	me := c.Me()
+
+	userLen := len(me.User)
+	if userLen == 0 {
+		// the server may add a ~ when identd doesn't answer
+		userLen = len(c.cfg.User) + 1
+	}
+	hostLen := len(me.Host)
+	if hostLen == 0 {
+		hostLen = maxHostLen
+	}
+
+	// ":nick!user@host COMMAND target :text\r\n"
+	prefix := 1 + len(me.Nick) + 1 + userLen + 1 + hostLen + 1
+	return irc.MaxMessageLen - 2 - prefix - len(command) - 1 - len(target) - 2
+}
+
+// sendText sends a PRIVMSG or NOTICE split into as many lines as needed
+func (c *Client) sendText(command string, tags map[string]string, target, message string) error {
+	budget := c.textBudget(command, target)
+	if budget <= 0 {
+		return fmt.Errorf("target %q: %w", target, irc.ErrInvalidParam)
+	}
+
+	lines := limitLines(SplitMessage(message, budget), c.cfg.MaxLines, budget)
+	for _, line := range lines {
+		if err := c.SendMsg(irc.NewMsg(command, target).WithTrailing(line).WithTags(tags)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+func TestSplitMessage(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		max  int
+		want []string
+	}{
+		{name: "short", text: "hello world", max: 20, want: []string{"hello world"}},
+		{name: "empty", text: "", max: 20, want: []string{""}},
+		{name: "words", text: "aaa bbb ccc ddd", max: 8, want: []string{"aaa bbb", "ccc ddd"}},
+		{name: "space at the limit", text: "aaaa bbbb", max: 4, want: []string{"aaaa", "bbbb"}},
+		{name: "long word", text: "abcdefghij", max: 4, want: []string{"abcd", "efgh", "ij"}},
+		{name: "line breaks", text: "one\r\ntwo\n\nthree", max: 20, want: []string{"one", "two", "three"}},
+		{name: "runes", text: "ééééé", max: 5, want: []string{"éé", "éé", "é"}},
+		{name: "color code", text: "ab\x0304,12cd", max: 6, want: []string{"ab", "\x0304,12", "cd"}},
+		{name: "color code kept", text: "a\x0304,12b", max: 7, want: []string{"a\x0304,12", "b"}},
+		{name: "hex color", text: "a\x04ff0000b", max: 7, want: []string{"a", "\x04ff0000", "b"}},
+		{name: "bold", text: "\x02bold\x02 text", max: 6, want: []string{"\x02bold\x02", "text"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, SplitMessage(c.text, c.max))
+		})
+	}
+}
+
+func TestSplitMessageProperties(t *testing.T) {
+	text := strings.Repeat("héllo \x0312wörld\x03 \x02日本語\x02 ", 50)
+
+	for max := 8; max < 100; max++ {
+		lines := SplitMessage(text, max)
+		for _, line := range lines {
+			assert.LessOrEqual(t, len(line), max)
+			assert.True(t, utf8.ValidString(line), line)
+		}
+		assert.Equal(t,
+			strings.ReplaceAll(text, " ", ""),
+			strings.ReplaceAll(strings.Join(lines, ""), " ", ""),
+		)
+	}
+}
+
+func TestLimitLines(t *testing.T) {
+	lines := []string{"one two", "three four", "five"}
+
+	assert.Equal(t, lines, limitLines(lines, 0, 20))
+	assert.Equal(t, lines, limitLines(lines, 3, 20))
+	assert.Equal(t, []string{"one two", "three four five …more"}, limitLines(lines, 2, 30))
+	assert.Equal(t, []string{"one two", "three …more"}, limitLines(lines, 2, 15))
+}
+
+func TestSendLongMessage(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	client, conn := newTestClient(t, cfg)
+
+	receive(t, client, ":gral!~gral@some.host.example JOIN #chan")
+	conn.lines()
+
+	message := strings.TrimSpace(strings.Repeat("word ", 300))
+	require.NoError(t, client.SendPRIVMSG("#chan", message))
+
+	lines := conn.lines()
+	require.Greater(t, len(lines), 1)
+
+	prefix := ":gral!~gral@some.host.example "
+	for _, line := range lines {
+		assert.True(t, strings.HasPrefix(line, "PRIVMSG #chan :word"), line)
+		// the line as relayed by the server fits in 512 bytes
+		assert.LessOrEqual(t, len(prefix+line+"\r\n"), irc.MaxMessageLen)
+	}
+	assert.Equal(t, message, strings.Join(
+		func() []string {
+			texts := make([]string, 0, len(lines))
+			for _, line := range lines {
+				texts = append(texts, strings.TrimPrefix(line, "PRIVMSG #chan :"))
+			}
+			return texts
+		}(), " "))
+
+	// the budget is exactly used up
+	assert.Equal(t, irc.MaxMessageLen, len(prefix+"PRIVMSG #chan :")+client.textBudget("PRIVMSG", "#chan")+2)
+}
+
+func TestSendMaxLines(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxLines = 2
+	client, conn := newTestClient(t, cfg)
+
+	require.NoError(t, client.SendNOTICE("bob", strings.Repeat("word ", 300)))
+	lines := conn.lines()
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasSuffix(lines[1], " …more"))
+	for _, line := range lines {
+		assert.LessOrEqual(t, len(strings.TrimPrefix(line, "NOTICE bob :")), client.textBudget("NOTICE", "bob"))
+	}
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// ChannelSnapshot is a copy of the state of a channel, safe to keep and
+// read from any goroutine
+type ChannelSnapshot struct {
+	Name            string
+	Modes           ChannelModes
+	Topic           string
+	TopicChangeTime time.Time
+	TopicChangeBy   string
+	Users           []Member
+	Messages        []string
+
+	casemap     irc.CaseMapping
+	prefixModes string
+}
+
+// Snapshot copies the channel, the client lock must be held
+func (c *Channel) Snapshot() ChannelSnapshot {
+	users := make([]Member, 0, len(c.Users))
+	for _, u := range c.Users {
+		users = append(users, *u)
+	}
+
+	return ChannelSnapshot{
+		Name:            c.name,
+		Modes:           c.modes.clone(),
+		Topic:           c.Topic,
+		TopicChangeTime: c.TopicChangeTime,
+		TopicChangeBy:   c.TopicChangeBy,
+		Users:           users,
+		Messages:        slices.Clone(c.Messages),
+		casemap:         c.casemap,
+		prefixModes:     c.prefixModes,
+	}
+}
+
+// Channel returns a snapshot of a joined channel
+func (c *Client) Channel(name string) (ChannelSnapshot, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	channel, ok := c.channels[c.fold(name)]
+	if !ok {
+		return ChannelSnapshot{}, false
+	}
+	return channel.Snapshot(), true
+}
+
+// Channels returns snapshots of the joined channels sorted by name
+func (c *Client) Channels() []ChannelSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	channels := make([]ChannelSnapshot, 0, len(c.channels))
+	for _, channel := range c.channels {
+		channels = append(channels, channel.Snapshot())
+	}
+	slices.SortFunc(channels, func(a, b ChannelSnapshot) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return channels
+}
+
+// Me returns our own identity on the server
+func (c *Client) Me() irc.UserIdentity {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.me
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+func TestChannelSnapshot(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	client, _ := newTestClient(t, cfg)
+
+	receive(t, client,
+		":gral!gral@host JOIN #b",
+		":gral!gral@host JOIN #a",
+		":irc.test 332 gral #a :the topic",
+		":irc.test 353 gral = #a :gral @bob",
+		":irc.test 366 gral #a :End of /NAMES list.",
+	)
+
+	channels := client.Channels()
+	require.Len(t, channels, 2)
+	assert.Equal(t, "#a", channels[0].Name)
+	assert.Equal(t, "#b", channels[1].Name)
+
+	snapshot, ok := client.Channel("#a")
+	require.True(t, ok)
+	assert.Equal(t, "the topic", snapshot.Topic)
+	assert.Equal(t, []Member{
+		{UserIdentity: irc.UserIdentity{Nick: "gral"}},
+		{UserIdentity: irc.UserIdentity{Nick: "bob"}, Modes: "o"},
+	}, snapshot.Users)
+
+	// later changes don't show in the snapshot
+	receive(t, client, ":bob!b@host NICK robert", ":irc.test 332 gral #a :new topic")
+	assert.Equal(t, "bob", snapshot.Users[1].Nick)
+	assert.Equal(t, "the topic", snapshot.Topic)
+
+	_, ok = client.Channel("#c")
+	assert.False(t, ok)
+	assert.Equal(t, "gral", client.Me().Nick)
+}
+
+// TestClientConcurrentAccess is meant to run with -race
+func TestClientConcurrentAccess(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := NewClient(logger, cfg)
+
+	clientConn, serverConn := net.Pipe()
+	client.attach(clientConn)
+
+	// drain what the client sends, net.Pipe writes block until read
+	go func() { _, _ = io.Copy(io.Discard, serverConn) }()
+
+	done := make(chan error)
+	go func() { done <- client.ReadLoop() }()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		lines := []string{
+			":irc.test CAP * LS :message-tags multi-prefix",
+			":irc.test CAP * ACK :message-tags",
+		}
+		for i := range 50 {
+			lines = append(lines,
+				fmt.Sprintf(":gral!gral@host JOIN #chan%d", i%5),
+				fmt.Sprintf(":user%d!u@host JOIN #chan%d", i, i%5),
+				fmt.Sprintf(":irc.test 332 gral #chan%d :topic %d", i%5, i),
+				fmt.Sprintf(":user%d!u@host PRIVMSG #chan%d :!topic", i, i%5),
+				fmt.Sprintf(":user%d!u@host PRIVMSG gral :hello", i),
+				fmt.Sprintf(":user%d!u@host NICK other%d", i, i),
+				fmt.Sprintf(":other%d!u@host PART #chan%d", i, i%5),
+				":irc.test 221 gral +i",
+			)
+		}
+		for _, line := range lines {
+			_, err := serverConn.Write([]byte(line + "\r\n"))
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, channel := range client.Channels() {
+				_, _ = client.Channel(channel.Name)
+			}
+			_ = client.Me()
+			_ = client.Queries()
+			_ = client.Account()
+			_ = client.Caps()
+			_ = client.HasCap("message-tags")
+			_ = client.WantCap("server-time")
+			_ = client.JoinKey(fmt.Sprintf("#key%d", i), "secret")
+			_ = client.SendPRIVMSG("#chan0", "hello")
+			_ = client.Router().Register(BotCommand{Name: fmt.Sprintf("cmd%d", i), Run: func(*CommandContext) error { return nil }})
+		}()
+	}
+
+	wg.Wait()
+	require.NoError(t, serverConn.Close())
+	assert.Error(t, <-done)
+	client.detach()
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// client events emitted by the supervisor, and by the client once the
+// server welcomed us
+const (
+	EventConnected    = "CONNECTED"
+	EventRegistered   = "REGISTERED"
+	EventDisconnected = "DISCONNECTED"
+)
+
+// ErrPermanent marks the errors the supervisor doesn't retry
+var ErrPermanent = errors.New("permanent failure")
+
+type Backoff struct {
+	Min    time.Duration
+	Max    time.Duration
+	Factor float64
+	// Jitter is the fraction of the delay that is randomized
+	Jitter float64
+}
+
+// Delay returns how long to wait before the given retry attempt,
+// starting from 0
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := float64(b.Min)
+	for i := 0; i < attempt && d < float64(b.Max); i++ {
+		d *= b.Factor
+	}
+	d = min(d, float64(b.Max))
+
+	if b.Jitter > 0 {
+		d -= d * b.Jitter * rand.Float64()
+	}
+
+	return time.Duration(d)
+}
+
+type DialFunc func(ctx context.Context) (net.Conn, error)
+
+// Supervisor keeps the client connected, redialing with a backoff
+// whenever the connection is lost
+type Supervisor struct {
+	client  *Client
+	dial    DialFunc
+	backoff Backoff
+	logger  *slog.Logger
+}
+
+func NewSupervisor(client *Client, dial DialFunc, backoff Backoff, logger *slog.Logger) *Supervisor {
+	return &Supervisor{client: client, dial: dial, backoff: backoff, logger: logger}
+}
+
+// Run connects and serves the client until ctx is done or the connection
+// fails permanently
+func (s *Supervisor) Run(ctx context.Context) error {
+	attempt := 0
+	for {
+		welcomed, err := s.serve(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrPermanent) {
+			return err
+		}
+
+		// a connection the server welcomed resets the backoff
+		if welcomed {
+			attempt = 0
+		}
+
+		delay := s.backoff.Delay(attempt)
+		attempt++
+		s.logger.Error("connection lost", "error", err, "retry_in", delay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// serve runs a single connection until it fails, and reports whether the
+// server welcomed us on it
+func (s *Supervisor) serve(ctx context.Context) (bool, error) {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	s.client.attach(conn)
+	registered := s.client.Registered()
+	welcomed := func() bool {
+		select {
+		case <-s.client.Registered():
+			return true
+		default:
+			return false
+		}
+	}
+
+	// quit when ctx is done, unblock the read loop when the server doesn't
+	// welcome us in time or stops answering our pings
+	var dropped atomic.Value
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		var expired <-chan time.Time
+		if timeout := time.Duration(s.client.cfg.RegistrationTimeout); timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				s.client.quit(conn, done)
+				return
+			case <-done:
+				return
+			case <-registered:
+				registered, expired = nil, nil
+			case <-expired:
+				dropped.Store(ErrRegistrationTimeout)
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	defer func() {
+		conn.Close()
+		s.client.detach()
+		s.client.emit(EventDisconnected)
+	}()
+
+	s.client.emit(EventConnected)
+
+	go func() {
+		if err := s.client.keepalive(done); err != nil {
+			dropped.Store(err)
+			conn.Close()
+		}
+	}()
+
+	if err := s.client.Register(); err != nil {
+		return false, err
+	}
+
+	err = s.client.ReadLoop()
+	if reason, ok := dropped.Load().(error); ok {
+		err = reason
+	}
+	if reason := s.client.fatalError(); reason != nil {
+		err = reason
+	}
+	if err == nil {
+		err = errors.New("connection closed")
+	}
+	return welcomed(), err
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Min: time.Second, Max: 10 * time.Second, Factor: 2}
+
+	assert.Equal(t, time.Second, b.Delay(0))
+	assert.Equal(t, 2*time.Second, b.Delay(1))
+	assert.Equal(t, 8*time.Second, b.Delay(3))
+	assert.Equal(t, 10*time.Second, b.Delay(4))
+	assert.Equal(t, 10*time.Second, b.Delay(100))
+
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := b.Delay(1)
+		assert.GreaterOrEqual(t, d, time.Second)
+		assert.LessOrEqual(t, d, 2*time.Second)
+	}
+}
+
+func TestSupervisorReconnects(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	cfg.User = "gral"
+	cfg.Channels = []ChannelConfig{{Name: "#a"}}
+	cfg.ShutdownTimeout = Duration(50 * time.Millisecond)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := NewClient(logger, cfg)
+
+	events := make(chan string, 10)
+	client.On(EventConnected, func(irc.Msg) error { events <- EventConnected; return nil })
+	client.On(EventDisconnected, func(irc.Msg) error { events <- EventDisconnected; return nil })
+
+	servers := make(chan net.Conn)
+	dial := func(ctx context.Context) (net.Conn, error) {
+		clientConn, serverConn := net.Pipe()
+		select {
+		case servers <- serverConn:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return clientConn, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	supervisor := NewSupervisor(client, dial, Backoff{Min: time.Millisecond, Max: time.Millisecond, Factor: 2}, logger)
+	go func() { done <- supervisor.Run(ctx) }()
+
+	// first connection: register, join #a and a keyed channel, then drop
+	conn := <-servers
+	lines := bufio.NewScanner(conn)
+	expectLine := func(want string) {
+		t.Helper()
+		require.True(t, lines.Scan())
+		assert.Equal(t, want, lines.Text())
+	}
+	send := func(line string) {
+		t.Helper()
+		_, err := conn.Write([]byte(line + "\r\n"))
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, EventConnected, <-events)
+	expectLine("CAP LS 302")
+	expectLine("NICK gral")
+	expectLine("USER gral ignored ignored :gral.irc bot")
+	send(":irc.test 001 gral :Welcome")
+	send(":irc.test 376 gral :End of MOTD")
+	expectLine("JOIN #a")
+	send(":gral!gral@host JOIN #a")
+	expectLine("MODE #a")
+
+	go func() { _ = client.JoinKey("#b", "secret") }()
+	expectLine("JOIN #b secret")
+	send(":gral!gral@host JOIN #b")
+	conn.Close()
+
+	assert.Equal(t, EventDisconnected, <-events)
+
+	// second connection: register again and rejoin both channels
+	conn = <-servers
+	lines = bufio.NewScanner(conn)
+
+	assert.Equal(t, EventConnected, <-events)
+	expectLine("CAP LS 302")
+	expectLine("NICK gral")
+	expectLine("USER gral ignored ignored :gral.irc bot")
+	send(":irc.test 376 gral :End of MOTD")
+
+	joins := make([]string, 0)
+	for i := 0; i < 2; i++ {
+		require.True(t, lines.Scan())
+		joins = append(joins, lines.Text())
+	}
+	assert.ElementsMatch(t, []string{"JOIN #a", "JOIN #b secret"}, joins)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestSupervisorRegistrationTimeout(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	cfg.RegistrationTimeout = Duration(20 * time.Millisecond)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := NewClient(logger, cfg)
+
+	servers := make(chan net.Conn)
+	dial := func(ctx context.Context) (net.Conn, error) {
+		clientConn, serverConn := net.Pipe()
+		select {
+		case servers <- serverConn:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return clientConn, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	supervisor := NewSupervisor(client, dial, Backoff{Min: time.Millisecond, Max: time.Millisecond, Factor: 2}, logger)
+	welcomed := make(chan bool, 1)
+	done := make(chan error)
+	go func() {
+		ok, err := supervisor.serve(ctx)
+		welcomed <- ok
+		done <- err
+	}()
+
+	// read the registration and never welcome the client
+	conn := <-servers
+	go func() { _, _ = io.Copy(io.Discard, conn) }()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrRegistrationTimeout)
+		assert.False(t, <-welcomed)
+	case <-ctx.Done():
+		t.Fatal("connection not dropped")
+	}
+}
+
+func TestSupervisorStopsOnPermanentError(t *testing.T) {
+	cfg := saslConfig(SASLConfig{Mechanism: SASLPlain, Username: "gral", Password: "bad", Required: true})
+	cfg.Nick = "gral"
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := NewClient(logger, cfg)
+
+	dials := 0
+	servers := make(chan net.Conn)
+	dial := func(ctx context.Context) (net.Conn, error) {
+		dials++
+		clientConn, serverConn := net.Pipe()
+		select {
+		case servers <- serverConn:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return clientConn, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	supervisor := NewSupervisor(client, dial, Backoff{Min: time.Millisecond, Max: time.Millisecond, Factor: 2}, logger)
+	go func() { done <- supervisor.Run(ctx) }()
+
+	// fail the authentication and hang up once the client quits
+	conn := <-servers
+	lines := bufio.NewScanner(conn)
+	send := func(line string) {
+		t.Helper()
+		_, err := conn.Write([]byte(line + "\r\n"))
+		require.NoError(t, err)
+	}
+	for lines.Scan() {
+		switch lines.Text() {
+		case "CAP LS 302":
+			send(":irc.test CAP * LS :sasl")
+		case "CAP REQ :sasl":
+			send(":irc.test CAP * ACK :sasl")
+		case "AUTHENTICATE PLAIN":
+			send("AUTHENTICATE +")
+		case "AUTHENTICATE Z3JhbABncmFsAGJhZA==":
+			send(":irc.test 904 gral :SASL authentication failed")
+		case "QUIT :SASL authentication failed":
+			conn.Close()
+		}
+	}
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrSASLFailed)
+		assert.ErrorIs(t, err, ErrPermanent)
+	case <-ctx.Done():
+		t.Fatal("supervisor kept reconnecting")
+	}
+	assert.Equal(t, 1, dials)
+}
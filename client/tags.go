@@ -0,0 +1,73 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// outgoingTags keeps the tags the server accepts from us: client-only
+// tags need the message-tags capability
+func (c *Client) outgoingTags(tags map[string]string) (map[string]string, error) {
+	if len(tags) == 0 || !c.HasCap("message-tags") {
+		if len(tags) > 0 {
+			c.logger.Debug("message-tags not enabled, dropping tags", "tags", tags)
+		}
+		return nil, nil
+	}
+
+	clientOnly := make(map[string]string)
+	for key, value := range tags {
+		if irc.IsClientOnlyTag(key) {
+			clientOnly[key] = value
+		}
+	}
+	if len(irc.FormatTags(clientOnly)) > irc.MaxClientTagsLen {
+		return nil, irc.ErrTagsTooLong
+	}
+
+	return tags, nil
+}
+
+// send PRIVMSG with tags such as +draft/reply
+func (c *Client) SendPRIVMSGWithTags(tags map[string]string, target, message string) error {
+	if err := c.sendText("PRIVMSG", tags, target, message); err != nil {
+		return fmt.Errorf("error sending message: %w", err)
+	}
+	return nil
+}
+
+// send NOTICE with tags
+func (c *Client) SendNOTICEWithTags(tags map[string]string, target, message string) error {
+	if err := c.sendText("NOTICE", tags, target, message); err != nil {
+		return fmt.Errorf("error sending notice: %w", err)
+	}
+	return nil
+}
+
+// send TAGMSG, a message made of tags only such as +typing
+func (c *Client) SendTAGMSG(tags map[string]string, target string) error {
+	if !c.HasCap("message-tags") {
+		return nil
+	}
+	if err := c.SendMsg(irc.NewMsg("TAGMSG", target).WithTags(tags)); err != nil {
+		return fmt.Errorf("error sending tagmsg: %w", err)
+	}
+	return nil
+}
+
+// Reply answers msg in the same target, threading the reply with
+// +draft/reply when the server gave the message an id
+func (c *Client) Reply(msg irc.Msg, message string) error {
+	target := msg.Target
+	if c.CaseMapping().Equal(target, c.Me().Nick) {
+		target = msg.Nick
+	}
+
+	tags := make(map[string]string)
+	if id, ok := msg.Tags["msgid"]; ok {
+		tags["+draft/reply"] = id
+	}
+
+	return c.SendPRIVMSGWithTags(tags, target, message)
+}
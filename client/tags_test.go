@@ -0,0 +1,37 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+func TestSendTags(t *testing.T) {
+	client, conn := newTestClient(t, DefaultConfig())
+
+	// tags are dropped without message-tags
+	require.NoError(t, client.SendPRIVMSGWithTags(map[string]string{"+draft/reply": "abc"}, "#chan", "hi"))
+	require.NoError(t, client.SendTAGMSG(map[string]string{"+typing": "active"}, "#chan"))
+	assert.Equal(t, []string{"PRIVMSG #chan :hi"}, conn.lines())
+
+	client.caps.enabled["message-tags"] = ""
+
+	require.NoError(t, client.SendPRIVMSGWithTags(map[string]string{"+draft/reply": "a;b"}, "#chan", "hi"))
+	require.NoError(t, client.SendTAGMSG(map[string]string{"+typing": "active"}, "#chan"))
+	assert.Equal(t, []string{
+		`@+draft/reply=a\:b PRIVMSG #chan :hi`,
+		"@+typing=active TAGMSG #chan",
+	}, conn.lines())
+
+	msg, err := irc.ParseMessage("@msgid=123 :nick!user@host PRIVMSG #chan :!topic")
+	require.NoError(t, err)
+	require.NoError(t, client.Reply(*msg, "no topic"))
+	assert.Equal(t, []string{"@+draft/reply=123 PRIVMSG #chan :no topic"}, conn.lines())
+
+	long := make([]byte, irc.MaxClientTagsLen)
+	err = client.SendTAGMSG(map[string]string{"+big": string(long)}, "#chan")
+	assert.ErrorIs(t, err, irc.ErrTagsTooLong)
+}
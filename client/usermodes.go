@@ -0,0 +1,72 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+// applyUserModes applies a mode string such as "+iB-w" to a set of user
+// modes such as "iw"
+func applyUserModes(set, modes string) string {
+	add := true
+	for i := 0; i < len(modes); i++ {
+		switch mode := modes[i]; mode {
+		case '+':
+			add = true
+		case '-':
+			add = false
+		default:
+			set = strings.ReplaceAll(set, string(mode), "")
+			if add {
+				set += string(mode)
+			}
+		}
+	}
+	return set
+}
+
+// validateUserModes checks a mode string such as "+iB-w"
+func validateUserModes(modes string) error {
+	if modes[0] != '+' && modes[0] != '-' {
+		return errors.New("must start with + or -")
+	}
+	for i := 1; i < len(modes); i++ {
+		b := modes[i]
+		if b != '+' && b != '-' && (b < 'a' || b > 'z') && (b < 'A' || b > 'Z') {
+			return errors.New("modes must be letters")
+		}
+	}
+	return nil
+}
+
+// UserModes returns our user modes such as "iB"
+func (c *Client) UserModes() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.userModes
+}
+
+// HasUserMode reports whether one of our user modes is set, such as 'B'
+// for bots
+func (c *Client) HasUserMode(mode byte) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return strings.IndexByte(c.userModes, mode) >= 0
+}
+
+// SetUserModes asks the server to change our user modes, such as "+B-w",
+// they are updated once it confirms with a MODE
+func (c *Client) SetUserModes(modes string) error {
+	if modes == "" || validateUserModes(modes) != nil {
+		return fmt.Errorf("user modes %q: %w", modes, irc.ErrInvalidParam)
+	}
+
+	c.mu.RLock()
+	nick := c.me.Nick
+	c.mu.RUnlock()
+
+	return c.SendMODE(nick, modes)
+}
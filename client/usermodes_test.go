@@ -0,0 +1,54 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grentenrg/gral.irc/irc"
+)
+
+func TestApplyUserModes(t *testing.T) {
+	tests := []struct {
+		set, modes, want string
+	}{
+		{"", "+iw", "iw"},
+		{"iw", "-w", "i"},
+		{"iw", "+B-i+x", "wBx"},
+		{"i", "+i", "i"},
+		{"i", "-B", "i"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, applyUserModes(tt.set, tt.modes), "%s %s", tt.set, tt.modes)
+	}
+}
+
+func TestUserModes(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Nick = "gral"
+	cfg.UserModes = "+B"
+	client, conn := newTestClient(t, cfg)
+
+	receive(t, client, ":irc.test 001 gral :Welcome gral!gral@host")
+	assert.Equal(t, []string{"MODE gral +B"}, conn.lines())
+
+	receive(t, client,
+		":gral MODE gral :+iwB",
+		":irc.test MODE gral -w",
+		// not ours
+		":op!o@host MODE other +i",
+	)
+	assert.Equal(t, "iB", client.UserModes())
+	assert.True(t, client.HasUserMode('B'))
+	assert.False(t, client.HasUserMode('w'))
+
+	receive(t, client, ":irc.test 221 gral +Zi")
+	assert.Equal(t, "Zi", client.UserModes())
+
+	require.NoError(t, client.SetUserModes("-i+x"))
+	assert.Equal(t, []string{"MODE gral -i+x"}, conn.lines())
+	assert.ErrorIs(t, client.SetUserModes("x"), irc.ErrInvalidParam)
+	assert.ErrorIs(t, client.SetUserModes(""), irc.ErrInvalidParam)
+}
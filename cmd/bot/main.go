@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/grentenrg/gral.irc/client"
+)
+
+func main() {
+	cfg, err := client.LoadConfig(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	addr := cfg.Server.Addr
+
+	logger := slog.New(
+		slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: cfg.SlogLevel()}),
+	)
+
+	logger.Info("connecting to server", "addr", addr)
+
+	bot := client.NewClient(logger, cfg)
+
+	// quit on SIGINT or SIGTERM, a second signal kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		logger.Info("shutting down")
+		stop()
+	}()
+
+	if err := bot.Run(ctx); err != nil {
+		logger.Error("client stopped", "error", err)
+		os.Exit(1)
+	}
+}
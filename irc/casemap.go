@@ -0,0 +1,54 @@
+package irc
+
+import (
+	"strings"
+)
+
+// CaseMapping tells which nicks and channel names are equal, as given by
+// the CASEMAPPING ISUPPORT token
+type CaseMapping string
+
+const (
+	// CaseMappingASCII folds A-Z only
+	CaseMappingASCII CaseMapping = "ascii"
+	// CaseMappingRFC1459 also folds []\~ into {}|^, it is the default
+	CaseMappingRFC1459 CaseMapping = "rfc1459"
+	// CaseMappingStrictRFC1459 folds []\ into {}| but not ~
+	CaseMappingStrictRFC1459 CaseMapping = "strict-rfc1459"
+)
+
+// ParseCaseMapping returns the casemapping named by the server, rfc1459
+// for unknown ones
+func ParseCaseMapping(name string) CaseMapping {
+	switch m := CaseMapping(strings.ToLower(name)); m {
+	case CaseMappingASCII, CaseMappingRFC1459, CaseMappingStrictRFC1459:
+		return m
+	}
+	return CaseMappingRFC1459
+}
+
+// Fold returns the lower case form of s, equal names fold to the same string
+func (m CaseMapping) Fold(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		case m == CaseMappingASCII:
+			return r
+		case r == '[':
+			return '{'
+		case r == ']':
+			return '}'
+		case r == '\\':
+			return '|'
+		case r == '~' && m != CaseMappingStrictRFC1459:
+			return '^'
+		}
+		return r
+	}, s)
+}
+
+// Equal reports whether a and b are the same nick or channel name
+func (m CaseMapping) Equal(a, b string) bool {
+	return m.Fold(a) == m.Fold(b)
+}
@@ -0,0 +1,34 @@
+package irc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaseMappingFold(t *testing.T) {
+	cases := []struct {
+		mapping CaseMapping
+		in      string
+		want    string
+	}{
+		{CaseMappingASCII, "Nick[]\\~", "nick[]\\~"},
+		{CaseMappingRFC1459, "Nick[]\\~", "nick{}|^"},
+		{CaseMappingStrictRFC1459, "Nick[]\\~", "nick{}|~"},
+		{CaseMappingRFC1459, "#Gral.IRC", "#gral.irc"},
+		{CaseMappingASCII, "ÉCOLE", "École"},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.mapping)+" "+c.in, func(t *testing.T) {
+			assert.Equal(t, c.want, c.mapping.Fold(c.in))
+		})
+	}
+
+	assert.True(t, CaseMappingRFC1459.Equal("[bot]Gral", "{BOT}gral"))
+	assert.False(t, CaseMappingASCII.Equal("[bot]Gral", "{BOT}gral"))
+
+	assert.Equal(t, CaseMappingASCII, ParseCaseMapping("ASCII"))
+	assert.Equal(t, CaseMappingStrictRFC1459, ParseCaseMapping("strict-rfc1459"))
+	assert.Equal(t, CaseMappingRFC1459, ParseCaseMapping("rfc7613"))
+}
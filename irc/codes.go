@@ -1,4 +1,4 @@
-package main
+package irc
 
 var Commands = map[string]string{
 	// Commands
@@ -48,6 +48,11 @@ var Commands = map[string]string{
 	"WHOIS":    "WHOIS",    // Query user info
 	"WHOWAS":   "WHOWAS",   // Query offline user
 
+	// IRCv3 Commands
+	"CAP":          "CAP",          // Capability negotiation
+	"AUTHENTICATE": "AUTHENTICATE", // SASL authentication
+	"TAGMSG":       "TAGMSG",       // Message with tags only
+
 	// Numeric Replies
 	"001": "RPL_WELCOME",         // Welcome to the network
 	"002": "RPL_YOURHOST",        // Your host is
@@ -213,6 +218,17 @@ var Commands = map[string]string{
 	"491": "ERR_NOOPERHOST",        // No oper host
 	"501": "ERR_UMODEUNKNOWNFLAG",  // Mode unknown flag
 	"502": "ERR_USERSDONTMATCH",    // Users don't match
+
+	// SASL Replies
+	"900": "RPL_LOGGEDIN",    // Logged in
+	"901": "RPL_LOGGEDOUT",   // Logged out
+	"902": "ERR_NICKLOCKED",  // Nick locked
+	"903": "RPL_SASLSUCCESS", // SASL success
+	"904": "ERR_SASLFAIL",    // SASL failed
+	"905": "ERR_SASLTOOLONG", // SASL message too long
+	"906": "ERR_SASLABORTED", // SASL aborted
+	"907": "ERR_SASLALREADY", // Already authenticated
+	"908": "RPL_SASLMECHS",   // Available mechanisms
 }
 
 var RCommands = map[string]string{}
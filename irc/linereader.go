@@ -0,0 +1,73 @@
+package irc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrLineTooLong = errors.New("line too long")
+
+// DefaultMaxLineLen fits the largest tags section and a full message
+const DefaultMaxLineLen = MaxTagsLen + MaxMessageLen
+
+// LineReader reads IRC lines from a stream, buffering at most one line.
+// Lines may end with CRLF or a bare LF and empty lines are skipped.
+type LineReader struct {
+	r      *bufio.Reader
+	maxLen int
+	// discarding is set while skipping the rest of a line too long
+	discarding bool
+}
+
+// NewLineReader returns a reader of lines of at most maxLen bytes, line
+// ending excluded
+func NewLineReader(r io.Reader, maxLen int) *LineReader {
+	return &LineReader{r: bufio.NewReaderSize(r, maxLen+2), maxLen: maxLen}
+}
+
+// ReadLine returns the next line without its line ending. A line longer
+// than the maximum is skipped and reported once with ErrLineTooLong, the
+// next call goes on with the following line. Data left unterminated at the
+// end of the stream is reported with ErrNotCRLFTerminated.
+func (l *LineReader) ReadLine() (string, error) {
+	for {
+		line, err := l.r.ReadSlice('\n')
+
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			if l.discarding {
+				continue
+			}
+			l.discarding = true
+			return "", fmt.Errorf("%w: over %d bytes", ErrLineTooLong, l.maxLen)
+
+		case errors.Is(err, io.EOF):
+			if len(line) > 0 || l.discarding {
+				l.discarding = false
+				return "", fmt.Errorf("%w: %w", ErrNotCRLFTerminated, io.ErrUnexpectedEOF)
+			}
+			return "", io.EOF
+
+		case err != nil:
+			return "", err
+		}
+
+		if l.discarding {
+			l.discarding = false
+			continue
+		}
+
+		line = bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'})
+		if len(line) == 0 {
+			continue
+		}
+		if len(line) > l.maxLen {
+			return "", fmt.Errorf("%w: %d bytes", ErrLineTooLong, len(line))
+		}
+
+		return string(line), nil
+	}
+}
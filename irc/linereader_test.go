@@ -0,0 +1,132 @@
+package irc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chunkReader returns the data in chunks of the given sizes, cycling
+type chunkReader struct {
+	data  []byte
+	sizes []byte
+	i     int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+
+	size := 1
+	if len(r.sizes) > 0 {
+		size = int(r.sizes[r.i%len(r.sizes)]) + 1
+		r.i++
+	}
+	n := copy(p, r.data[:min(size, len(r.data))])
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// readAll collects lines, "!toolong" for lines too long, and the final error
+func readAll(r *LineReader) ([]string, error) {
+	lines := make([]string, 0)
+	for {
+		line, err := r.ReadLine()
+		if errors.Is(err, ErrLineTooLong) {
+			lines = append(lines, "!toolong")
+			continue
+		}
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestLineReader(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr error
+	}{
+		{name: "empty", input: "", want: []string{}, wantErr: io.EOF},
+		{name: "crlf", input: "PING :a\r\nPING :b\r\n", want: []string{"PING :a", "PING :b"}, wantErr: io.EOF},
+		{name: "bare lf", input: "PING :a\nPING :b\n", want: []string{"PING :a", "PING :b"}, wantErr: io.EOF},
+		{name: "empty lines", input: "\r\n\nPING :a\r\n\r\n", want: []string{"PING :a"}, wantErr: io.EOF},
+		{name: "unterminated", input: "PING :a\r\nPIN", want: []string{"PING :a"}, wantErr: ErrNotCRLFTerminated},
+		{name: "at max", input: strings.Repeat("x", 16) + "\r\nPING\r\n", want: []string{strings.Repeat("x", 16), "PING"}, wantErr: io.EOF},
+		{name: "too long", input: strings.Repeat("x", 17) + "\r\nPING\r\n", want: []string{"!toolong", "PING"}, wantErr: io.EOF},
+		{name: "too long bare lf", input: strings.Repeat("x", 17) + "\nPING\n", want: []string{"!toolong", "PING"}, wantErr: io.EOF},
+		{name: "much too long", input: strings.Repeat("x", 100) + "\r\nPING\r\n", want: []string{"!toolong", "PING"}, wantErr: io.EOF},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, r := range []io.Reader{
+				strings.NewReader(c.input),
+				iotest.OneByteReader(strings.NewReader(c.input)),
+				iotest.DataErrReader(strings.NewReader(c.input)),
+			} {
+				lines, err := readAll(NewLineReader(r, 16))
+				assert.Equal(t, c.want, lines)
+				assert.ErrorIs(t, err, c.wantErr)
+			}
+		})
+	}
+}
+
+// referenceLines splits data the slow way: the expected lines, "!toolong"
+// markers and whether data is left unterminated
+func referenceLines(data []byte, maxLen int) ([]string, []byte) {
+	parts := bytes.Split(data, []byte{'\n'})
+	lines := make([]string, 0)
+	for _, part := range parts[:len(parts)-1] {
+		part = bytes.TrimSuffix(part, []byte{'\r'})
+		switch {
+		case len(part) == 0:
+		case len(part) > maxLen:
+			lines = append(lines, "!toolong")
+		default:
+			lines = append(lines, string(part))
+		}
+	}
+	return lines, parts[len(parts)-1]
+}
+
+func FuzzLineReader(f *testing.F) {
+	f.Add([]byte("PING :a\r\nPING :b\r\n"), []byte{0})
+	f.Add([]byte("PING :a\nPI"), []byte{1, 7})
+	f.Add([]byte("\r\n\r\n\n"), []byte{2})
+	f.Add([]byte(strings.Repeat("x", 40)+"\r\nok\r\n"), []byte{3, 0, 9})
+	f.Add([]byte("a\rb\r\r\n"), []byte{})
+
+	const maxLen = 16
+
+	f.Fuzz(func(t *testing.T, data []byte, sizes []byte) {
+		want, rest := referenceLines(data, maxLen)
+
+		lines, err := readAll(NewLineReader(&chunkReader{data: data, sizes: sizes}, maxLen))
+
+		if len(rest) == 0 {
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, want, lines)
+			return
+		}
+
+		// an unterminated tail long enough may also be reported as too long
+		assert.ErrorIs(t, err, ErrNotCRLFTerminated)
+		if len(lines) > len(want) {
+			assert.Greater(t, len(rest), maxLen)
+			assert.Equal(t, "!toolong", lines[len(lines)-1])
+			lines = lines[:len(lines)-1]
+		}
+		assert.Equal(t, want, lines)
+	})
+}
@@ -0,0 +1,465 @@
+// Package irc parses and encodes IRC messages
+package irc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Message struct {
+	Name    string
+	Command string
+	Params  []string
+}
+
+func (m Message) String() string {
+	return fmt.Sprintf("Name: %s, Command: %s, Params: %v", m.Name, m.Command, m.Params)
+}
+
+func (m Message) CommandName() string {
+	if name, ok := Commands[m.Command]; ok {
+		return name
+	}
+
+	return m.Command
+}
+
+func parseMessage(msg string) (Message, error) {
+	if len(msg) == 0 {
+		return Message{}, errors.New("empty message")
+	}
+
+	if msg[0] == ':' {
+		spl := strings.SplitN(msg, " ", 3)
+		if len(spl) != 3 {
+			return Message{}, errors.New("invalid message")
+		}
+
+		return Message{
+			Name:    spl[0][1:],
+			Command: spl[1],
+			Params:  strings.Split(spl[2], " "),
+		}, nil
+	} else {
+		spl := strings.SplitN(msg, " ", 2)
+		if len(spl) != 2 {
+			return Message{}, errors.New("invalid message")
+		}
+
+		return Message{
+			Command: spl[0],
+			Params:  strings.Split(spl[1], " "),
+		}, nil
+	}
+}
+
+var ErrEmptyPacket = errors.New("empty packet")
+
+func parsePacketOld(in []byte) ([]string, error) {
+	if len(in) == 0 {
+		return nil, ErrEmptyPacket
+	}
+
+	packets := make([]string, 0)
+
+	for i := 0; i < len(in); {
+		if i+1 >= len(in) {
+			break
+		}
+
+		if in[i+1] == '\n' && in[i] == '\r' {
+			packets = append(packets, string(in[0:i]))
+			in = in[i+2:]
+			i = 0
+			continue
+		}
+
+		i++
+	}
+
+	rest := string(in)
+	if len(rest) > 0 {
+		packets = append(packets, rest)
+	}
+
+	return packets, nil
+}
+
+func parsePacket(in []byte) ([]string, string, error) {
+	rest := ""
+	if len(in) == 0 {
+		return nil, rest, ErrEmptyPacket
+	}
+
+	// Preallocate slice based on estimated packet count
+	estimatedPackets := bytes.Count(in, []byte{'\r', '\n'})
+	packets := make([]string, 0, estimatedPackets)
+
+	// Split but keep non-empty segments only
+	for len(in) > 0 {
+		idx := bytes.Index(in, []byte{'\r', '\n'})
+		if idx == -1 {
+			// No more CRLF, append remaining if not empty
+			if len(in) > 0 {
+				rest = string(in)
+			}
+			break
+		}
+
+		// Append segment if not empty
+		if idx > 0 {
+			packets = append(packets, string(in[:idx]))
+		}
+
+		// Move past the CRLF
+		in = in[idx+2:]
+	}
+
+	return packets, rest, nil
+}
+
+type UserIdentity struct {
+	Nick string
+	User string
+	Host string
+}
+
+func ParseUserIdentity(in string) (UserIdentity, error) {
+	if len(in) == 0 {
+		return UserIdentity{}, errors.New("empty name")
+	}
+
+	spl := strings.SplitN(in, "!", 2)
+	if len(spl) != 2 {
+		return UserIdentity{}, errors.New("invalid name")
+	}
+
+	spl2 := strings.SplitN(spl[1], "@", 2)
+	if len(spl2) != 2 {
+		return UserIdentity{}, errors.New("invalid name")
+	}
+
+	return UserIdentity{
+		Nick: spl[0],
+		User: spl2[0],
+		Host: spl2[1],
+	}, nil
+}
+
+type Msg struct {
+	Raw      string            // Raw message
+	Prefix   string            // Message prefix (sender)
+	Nick     string            // Nickname part of prefix
+	User     string            // Username part of prefix
+	Host     string            // Hostname part of prefix
+	Command  string            // IRC command or numeric
+	Target   string            // Message target (channel or user)
+	Args     []string          // Command arguments
+	Trailing string            // Trailing argument (after :)
+	Tags     map[string]string // IRCv3 message tags
+}
+
+var ErrInvalidParam = errors.New("invalid parameter")
+
+// NewMsg builds a message to send, the last param is encoded as a
+// trailing one when needed
+func NewMsg(command string, params ...string) Msg {
+	return Msg{Command: command, Args: params}
+}
+
+// WithTrailing appends a trailing parameter, always encoded after a colon
+func (m Msg) WithTrailing(text string) Msg {
+	m.Args = append(slices.Clone(m.Args), text)
+	m.Trailing = text
+	return m
+}
+
+// WithTags sets the message tags
+func (m Msg) WithTags(tags map[string]string) Msg {
+	m.Tags = tags
+	return m
+}
+
+// hasTrailing reports whether the last argument must be encoded after a colon
+func (m Msg) hasTrailing() bool {
+	if len(m.Args) == 0 {
+		return false
+	}
+
+	last := m.Args[len(m.Args)-1]
+	return (m.Trailing != "" && last == m.Trailing) ||
+		last == "" || last[0] == ':' || strings.Contains(last, " ")
+}
+
+// Validate checks that the message can be encoded: only the last param may
+// be empty, contain spaces or start with a colon, and nothing may contain
+// CR, LF or NUL
+func (m Msg) Validate() error {
+	if m.Command == "" || strings.ContainsAny(m.Command, " \r\n\x00") {
+		return fmt.Errorf("command %q: %w", m.Command, ErrInvalidParam)
+	}
+	if strings.ContainsAny(m.Prefix, " \r\n\x00") {
+		return fmt.Errorf("prefix %q: %w", m.Prefix, ErrInvalidParam)
+	}
+
+	for i, arg := range m.Args {
+		if strings.ContainsAny(arg, "\r\n\x00") {
+			return fmt.Errorf("param %q: %w", arg, ErrInvalidParam)
+		}
+		if i < len(m.Args)-1 && (arg == "" || arg[0] == ':' || strings.Contains(arg, " ")) {
+			return fmt.Errorf("middle param %q: %w", arg, ErrInvalidParam)
+		}
+	}
+
+	return nil
+}
+
+// String encodes the message in wire format, without the CRLF. It is the
+// inverse of ParseMessage.
+func (m Msg) String() string {
+	var b strings.Builder
+
+	if len(m.Tags) > 0 {
+		b.WriteByte('@')
+		b.WriteString(FormatTags(m.Tags))
+		b.WriteByte(' ')
+	}
+
+	if m.Prefix != "" {
+		b.WriteByte(':')
+		b.WriteString(m.Prefix)
+		b.WriteByte(' ')
+	}
+
+	b.WriteString(m.Command)
+
+	for i, arg := range m.Args {
+		b.WriteByte(' ')
+		if i == len(m.Args)-1 && m.hasTrailing() {
+			b.WriteByte(':')
+		}
+		b.WriteString(arg)
+	}
+
+	return b.String()
+}
+
+// Bytes encodes the message in wire format, without the CRLF
+func (m Msg) Bytes() []byte {
+	return []byte(m.String())
+}
+
+// parse errors, ParseMessage only returns ErrEmptyMessage and ErrNoCommand
+// while ParseMessageStrict returns all of them
+var (
+	ErrNotCRLFTerminated = errors.New("not CRLF terminated")
+	ErrEmptyMessage      = errors.New("empty message")
+	ErrTooLong           = errors.New("message too long")
+	ErrIllegalChar       = errors.New("illegal character")
+	ErrBadTags           = errors.New("malformed tags")
+	ErrBadPrefix         = errors.New("malformed prefix")
+	ErrNoCommand         = errors.New("no command found")
+	ErrBadCommand        = errors.New("malformed command")
+	ErrTooManyParams     = errors.New("too many parameters")
+)
+
+const (
+	// maximum length of a message without its tags, CRLF included
+	MaxMessageLen = 512
+	// maximum length of the tags section, '@' and trailing space included
+	MaxTagsLen = 8191
+	// maximum number of parameters of a message
+	maxParams = 15
+)
+
+// ParseMessageStrict parses a line like ParseMessage but rejects what the
+// protocol does not allow: NUL, CR or LF inside the line, tags without a
+// message, malformed tag keys, prefixes and commands, more than 15
+// parameters and messages over the 512 bytes or 8191 bytes of tags limits.
+func ParseMessageStrict(line string) (*Msg, error) {
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, ErrEmptyMessage
+	}
+
+	if i := strings.IndexAny(line, "\x00\r\n"); i != -1 {
+		return nil, fmt.Errorf("%w: %q at %d", ErrIllegalChar, line[i], i)
+	}
+
+	rest := line
+	if strings.HasPrefix(rest, "@") {
+		idx := strings.IndexByte(rest, ' ')
+		if idx == -1 {
+			return nil, fmt.Errorf("%w: no message after tags", ErrBadTags)
+		}
+		if idx+1 > MaxTagsLen {
+			return nil, fmt.Errorf("%w: %d bytes", ErrTagsTooLong, idx+1)
+		}
+		if err := validateTags(rest[1:idx]); err != nil {
+			return nil, err
+		}
+		rest = strings.TrimLeft(rest[idx+1:], " ")
+	}
+
+	if len(rest)+2 > MaxMessageLen {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLong, len(rest)+2)
+	}
+
+	if strings.HasPrefix(rest, ":") {
+		idx := strings.IndexByte(rest, ' ')
+		if idx == -1 {
+			return nil, fmt.Errorf("%w: %w", ErrBadPrefix, ErrNoCommand)
+		}
+		if idx == 1 {
+			return nil, fmt.Errorf("%w: empty prefix", ErrBadPrefix)
+		}
+		rest = strings.TrimLeft(rest[idx+1:], " ")
+	}
+
+	if rest == "" || rest[0] == ':' {
+		return nil, ErrNoCommand
+	}
+
+	middle, _, hasTrailing := strings.Cut(rest, " :")
+	fields := strings.Fields(middle)
+	if len(fields) == 0 {
+		return nil, ErrNoCommand
+	}
+	if !validCommand(fields[0]) {
+		return nil, fmt.Errorf("%w: %q", ErrBadCommand, fields[0])
+	}
+
+	params := len(fields) - 1
+	if hasTrailing {
+		params++
+	}
+	if params > maxParams {
+		return nil, fmt.Errorf("%w: %d", ErrTooManyParams, params)
+	}
+
+	return ParseMessage(line)
+}
+
+// validCommand reports whether command is letters or a 3 digits numeric
+func validCommand(command string) bool {
+	isDigit := func(r rune) bool { return r >= '0' && r <= '9' }
+	isLetter := func(r rune) bool { return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') }
+
+	if len(command) == 3 && strings.IndexFunc(command, func(r rune) bool { return !isDigit(r) }) == -1 {
+		return true
+	}
+	return strings.IndexFunc(command, func(r rune) bool { return !isLetter(r) }) == -1
+}
+
+// validateTags checks tag keys: an optional '+', an optional vendor
+// hostname followed by '/', then letters, digits and hyphens
+func validateTags(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("%w: empty tags", ErrBadTags)
+	}
+
+	for _, tag := range strings.Split(raw, ";") {
+		key, _, _ := strings.Cut(tag, "=")
+		name := strings.TrimPrefix(key, "+")
+
+		if vendor, rest, ok := strings.Cut(name, "/"); ok {
+			if vendor == "" || strings.Trim(vendor, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789.-") != "" {
+				return fmt.Errorf("%w: bad vendor in %q", ErrBadTags, key)
+			}
+			name = rest
+		}
+
+		if name == "" || strings.Trim(name, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-") != "" {
+			return fmt.Errorf("%w: bad key %q", ErrBadTags, key)
+		}
+	}
+
+	return nil
+}
+
+func ParseMessage(line string) (*Msg, error) {
+	if len(line) == 0 {
+		return nil, ErrEmptyMessage
+	}
+
+	msg := &Msg{
+		Raw:  line,
+		Tags: make(map[string]string),
+	}
+
+	// Remove \r\n if present
+	line = strings.TrimRight(line, "\r\n")
+
+	// Parse IRCv3 tags if present
+	if strings.HasPrefix(line, "@") {
+		if idx := strings.Index(line, " "); idx != -1 {
+			msg.Tags = ParseTags(line[1:idx])
+			line = line[idx+1:]
+		}
+	}
+
+	// Parse prefix if present
+	if strings.HasPrefix(line, ":") {
+		if idx := strings.Index(line, " "); idx != -1 {
+			msg.Prefix = line[1:idx]
+			line = line[idx+1:]
+
+			// Parse prefix parts (nick!user@host)
+			if idx := strings.Index(msg.Prefix, "!"); idx != -1 {
+				msg.Nick = msg.Prefix[:idx]
+				if idx2 := strings.Index(msg.Prefix[idx+1:], "@"); idx2 != -1 {
+					msg.User = msg.Prefix[idx+1 : idx+1+idx2]
+					msg.Host = msg.Prefix[idx+1+idx2+1:]
+				}
+			} else if idx := strings.Index(msg.Prefix, "@"); idx != -1 {
+				msg.Nick = msg.Prefix[:idx]
+				msg.Host = msg.Prefix[idx+1:]
+			} else {
+				msg.Nick = msg.Prefix
+			}
+		}
+	}
+
+	// Parse command and arguments
+	parts := strings.SplitN(line, " :", 2)
+	args := strings.Fields(parts[0])
+
+	if len(args) == 0 {
+		return nil, ErrNoCommand
+	}
+
+	msg.Command = strings.ToUpper(args[0])
+
+	if len(args) > 1 {
+		msg.Args = args[1:]
+	}
+
+	// Add trailing argument if present
+	if len(parts) > 1 {
+		msg.Trailing = parts[1]
+		msg.Args = append(msg.Args, msg.Trailing)
+	}
+
+	// Set target for common commands, some servers send it as the trailing
+	// param, as in JOIN :#chan
+	switch msg.Command {
+	case "PRIVMSG", "NOTICE", "TAGMSG", "JOIN", "PART", "MODE", "TOPIC", "INVITE", "KICK":
+		if len(msg.Args) > 0 {
+			msg.Target = msg.Args[0]
+		}
+	}
+
+	return msg, nil
+}
+
+// Msg.CommandName
+func (m Msg) CommandName() string {
+	if name, ok := Commands[m.Command]; ok {
+		return name
+	}
+
+	return m.Command
+}
@@ -0,0 +1,335 @@
+package irc
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePacket(t *testing.T) {
+	type testCase struct {
+		name      string
+		input     []byte
+		want      []string
+		wantRest  string
+		wantError error
+	}
+
+	cases := []testCase{
+		{
+			name:      "empty",
+			input:     []byte{},
+			want:      nil,
+			wantRest:  "",
+			wantError: ErrEmptyPacket,
+		},
+		{
+			name:      "one packet",
+			input:     []byte("PING :tmi.twitch.tv\r\n"),
+			want:      []string{"PING :tmi.twitch.tv"},
+			wantRest:  "",
+			wantError: nil,
+		},
+		{
+			name:      "two packets",
+			input:     []byte("PING :tmi.twitch.tv\r\nPING :tmi.twitch.tv\r\n"),
+			want:      []string{"PING :tmi.twitch.tv", "PING :tmi.twitch.tv"},
+			wantRest:  "",
+			wantError: nil,
+		},
+		{
+			name:      "three partial packets",
+			input:     []byte(".tv\r\nPING :tmi.twitch.tv\r\nPIN"),
+			want:      []string{".tv", "PING :tmi.twitch.tv"},
+			wantRest:  "PIN",
+			wantError: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, rest, err := parsePacket(c.input)
+			if err != nil {
+				assert.EqualError(t, err, c.wantError.Error())
+			}
+
+			assert.Equal(t, c.want, res)
+			assert.Equal(t, c.wantRest, rest)
+
+			res2, rest2, err2 := parsePacket(c.input)
+			if err2 != nil {
+				assert.EqualError(t, err2, c.wantError.Error())
+			}
+			assert.Equal(t, c.want, res2)
+			assert.Equal(t, c.wantRest, rest2)
+		})
+	}
+}
+
+func BenchmarkParsePacket(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		data []byte
+	}{
+		{"SingleSmallPacket", []byte("small\r\n")},
+		{
+			"SingleLargePacket",
+			[]byte("large_packet_with_more_content_here_to_test_larger_sizes\r\n"),
+		},
+		{"MultiplePackets", []byte("packet1\r\npacket2\r\npacket3\r\n")},
+		{"PacketsWithoutTermination", []byte("packet1\r\npacket2\r\npacket3")},
+		{"EmptyPackets", []byte("\r\npacket1\r\n\r\npacket2\r\n")},
+		{"LargeMultiPacket", generateLargePackets(100)}, // 100 packets
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs() // Report memory allocations
+			b.ResetTimer()   // Reset timer before the loop
+
+			for i := 0; i < b.N; i++ {
+				packets, rest, err := parsePacket(bm.data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				// Prevent compiler optimization
+				_ = packets
+				_ = rest
+			}
+		})
+	}
+}
+
+// Helper function to generate large test data
+func generateLargePackets(n int) []byte {
+	// Pre-allocate builder with estimated size
+	var result []byte
+	packet := []byte("this_is_a_test_packet_with_reasonable_length\r\n")
+
+	// Allocate slice with exact size needed
+	result = make([]byte, 0, len(packet)*n)
+
+	// Generate n packets
+	for i := 0; i < n; i++ {
+		result = append(result, packet...)
+	}
+
+	return result
+}
+
+// Optional: Benchmark comparison with original function
+func BenchmarkParsePacketOld(b *testing.B) {
+	// Use same test cases as above
+	benchmarks := []struct {
+		name string
+		data []byte
+	}{
+		{"SingleSmallPacket", []byte("small\r\n")},
+		{
+			"SingleLargePacket",
+			[]byte("large_packet_with_more_content_here_to_test_larger_sizes\r\n"),
+		},
+		{"MultiplePackets", []byte("packet1\r\npacket2\r\npacket3\r\n")},
+		{"PacketsWithoutTermination", []byte("packet1\r\npacket2\r\npacket3")},
+		{"EmptyPackets", []byte("\r\npacket1\r\n\r\npacket2\r\n")},
+		{"LargeMultiPacket", generateLargePackets(100)}, // 100 packets
+	}
+
+	for _, bm := range benchmarks {
+		b.Run("Old_"+bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				packets, err := parsePacketOld(bm.data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				_ = packets
+			}
+		})
+	}
+}
+
+var messageFixtures = []string{
+	"PING :tmi.twitch.tv",
+	"PING :",
+	"CAP LS 302",
+	"CAP * LS * :multi-prefix sasl=PLAIN,EXTERNAL",
+	":irc.test 001 gral :Welcome to the network",
+	":irc.test 353 gral = #chan :@op +voice user",
+	":irc.test 333 gral #chan nick!user@host 1700000000",
+	":nick!user@host JOIN #chan",
+	":nick!user@host PRIVMSG #chan :!topic",
+	":nick!user@host PRIVMSG #chan ::-)",
+	":nick!user@host PRIVMSG #chan :",
+	":nick!user@host KICK #chan victim :bye bye",
+	":nick!user@host MODE #chan +kl secret 10",
+	":nick NICK newnick",
+	"@msgid=abc;time=2025-01-01T00:00:00.000Z :nick!user@host PRIVMSG #chan :hi there",
+	`@+draft/reply=a\:b\sc;flag :nick!user@host TAGMSG #chan`,
+}
+
+func TestMsgRoundTrip(t *testing.T) {
+	for _, line := range messageFixtures {
+		t.Run(line, func(t *testing.T) {
+			msg, err := ParseMessage(line)
+			assert.NoError(t, err)
+			assert.Equal(t, line, msg.String())
+			assert.Equal(t, []byte(line), msg.Bytes())
+		})
+	}
+}
+
+func TestParseMessageTarget(t *testing.T) {
+	cases := []struct {
+		line string
+		want string
+	}{
+		{line: ":nick!user@host JOIN #chan", want: "#chan"},
+		{line: ":nick!user@host JOIN :#chan", want: "#chan"},
+		{line: ":nick!user@host PART :#chan", want: "#chan"},
+		{line: ":nick!user@host PRIVMSG #chan :hi", want: "#chan"},
+		{line: ":nick!user@host KICK #chan victim :bye", want: "#chan"},
+		{line: ":irc.test 001 gral :Welcome", want: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.line, func(t *testing.T) {
+			msg, err := ParseMessage(c.line)
+			assert.NoError(t, err)
+			assert.Equal(t, c.want, msg.Target)
+		})
+	}
+}
+
+func TestMsgEncode(t *testing.T) {
+	cases := []struct {
+		name string
+		msg  Msg
+		want string
+	}{
+		{name: "no params", msg: NewMsg("QUIT"), want: "QUIT"},
+		{name: "middle params", msg: NewMsg("JOIN", "#chan", "key"), want: "JOIN #chan key"},
+		{name: "last param with spaces", msg: NewMsg("KICK", "#chan", "nick", "bye bye"), want: "KICK #chan nick :bye bye"},
+		{name: "empty last param", msg: NewMsg("KICK", "#chan", "nick", ""), want: "KICK #chan nick :"},
+		{name: "last param with colon", msg: NewMsg("PASS", ":secret"), want: "PASS ::secret"},
+		{name: "explicit trailing", msg: NewMsg("PRIVMSG", "#chan").WithTrailing("hi"), want: "PRIVMSG #chan :hi"},
+		{name: "tags", msg: NewMsg("TAGMSG", "#chan").WithTags(map[string]string{"+typing": "active"}), want: "@+typing=active TAGMSG #chan"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.NoError(t, c.msg.Validate())
+			assert.Equal(t, c.want, c.msg.String())
+		})
+	}
+
+	assert.ErrorIs(t, NewMsg("KICK", "#chan", "", "reason").Validate(), ErrInvalidParam)
+	assert.ErrorIs(t, NewMsg("KICK", "#chan", "two words", "reason").Validate(), ErrInvalidParam)
+	assert.ErrorIs(t, NewMsg("PRIVMSG", "#chan", "a\r\nQUIT").Validate(), ErrInvalidParam)
+}
+
+// encoding then parsing random messages gives back the same fields
+func TestMsgRoundTripProperty(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	alphabet := "abcXYZ019#&+-_[]{}\\|^!@.:;= "
+
+	word := func(allowSpace bool) string {
+		n := 1 + rng.IntN(8)
+		b := make([]byte, 0, n)
+		for len(b) < n {
+			ch := alphabet[rng.IntN(len(alphabet))]
+			if !allowSpace && (ch == ' ' || (len(b) == 0 && ch == ':')) {
+				continue
+			}
+			b = append(b, ch)
+		}
+		return string(b)
+	}
+
+	for i := 0; i < 1000; i++ {
+		params := make([]string, rng.IntN(5))
+		for j := range params {
+			params[j] = word(false)
+		}
+		msg := NewMsg("PRIVMSG", params...)
+		if rng.IntN(2) == 0 {
+			msg = msg.WithTrailing(word(true))
+		}
+		if rng.IntN(2) == 0 {
+			msg.Prefix = "nick!user@host"
+		}
+		if rng.IntN(2) == 0 {
+			msg.Tags = map[string]string{"+key": word(true), "flag": ""}
+		}
+
+		require.NoError(t, msg.Validate())
+
+		parsed, err := ParseMessage(msg.String())
+		require.NoError(t, err, msg.String())
+		assert.Equal(t, msg.String(), parsed.String())
+		assert.Equal(t, msg.Command, parsed.Command)
+		assert.Equal(t, msg.Prefix, parsed.Prefix)
+		if len(msg.Args) > 0 {
+			assert.Equal(t, msg.Args, parsed.Args, msg.String())
+		}
+		if len(msg.Tags) > 0 {
+			assert.Equal(t, msg.Tags, parsed.Tags)
+		}
+	}
+}
+
+func TestParseMessageStrict(t *testing.T) {
+	longTags := "@" + strings.Repeat("a", MaxTagsLen) + " PING :x"
+	longLine := "PRIVMSG #chan :" + strings.Repeat("x", MaxMessageLen)
+
+	cases := []struct {
+		name    string
+		line    string
+		wantErr error
+	}{
+		{name: "valid", line: ":nick!user@host PRIVMSG #chan :hello"},
+		{name: "valid with crlf", line: "PING :x\r\n"},
+		{name: "valid numeric", line: ":irc.test 001 gral :Welcome"},
+		{name: "valid tags", line: "@+example.com/key=v;time=x;flag PING :x"},
+		{name: "empty", line: "", wantErr: ErrEmptyMessage},
+		{name: "crlf only", line: "\r\n", wantErr: ErrEmptyMessage},
+		{name: "nul byte", line: "PRIVMSG #chan :a\x00b", wantErr: ErrIllegalChar},
+		{name: "bare cr", line: "PRIVMSG #chan :a\rb", wantErr: ErrIllegalChar},
+		{name: "tags without message", line: "@time=x", wantErr: ErrBadTags},
+		{name: "empty tags", line: "@ PING :x", wantErr: ErrBadTags},
+		{name: "bad tag key", line: "@bad_key=1 PING :x", wantErr: ErrBadTags},
+		{name: "bad vendor", line: "@/key=1 PING :x", wantErr: ErrBadTags},
+		{name: "tags too long", line: longTags, wantErr: ErrTagsTooLong},
+		{name: "too long", line: longLine, wantErr: ErrTooLong},
+		{name: "prefix only", line: ":irc.test", wantErr: ErrBadPrefix},
+		{name: "empty prefix", line: ": PING", wantErr: ErrBadPrefix},
+		{name: "no command", line: ":irc.test  :trailing", wantErr: ErrNoCommand},
+		{name: "bad command", line: "PR1VMSG #chan :hi", wantErr: ErrBadCommand},
+		{name: "bad numeric", line: "0001 gral :hi", wantErr: ErrBadCommand},
+		{name: "too many params", line: "CMD 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 :16", wantErr: ErrTooManyParams},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			msg, err := ParseMessageStrict(c.line)
+			if c.wantErr != nil {
+				assert.ErrorIs(t, err, c.wantErr)
+				assert.Nil(t, msg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, msg)
+		})
+	}
+
+	// the lenient parser returns the same sentinels
+	_, err := ParseMessage("")
+	assert.ErrorIs(t, err, ErrEmptyMessage)
+	_, err = ParseMessage(":irc.test ")
+	assert.ErrorIs(t, err, ErrNoCommand)
+}
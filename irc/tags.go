@@ -0,0 +1,99 @@
+package irc
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+var ErrTagsTooLong = errors.New("tags too long")
+
+// maximum size of the client-only tags sent with a message
+const MaxClientTagsLen = 4094
+
+var (
+	tagEscaper   = strings.NewReplacer(`\`, `\\`, ";", `\:`, " ", `\s`, "\r", `\r`, "\n", `\n`)
+	tagUnescapes = map[byte]byte{':': ';', 's': ' ', '\\': '\\', 'r': '\r', 'n': '\n'}
+)
+
+// EscapeTagValue escapes a tag value for the wire
+func EscapeTagValue(value string) string {
+	return tagEscaper.Replace(value)
+}
+
+// UnescapeTagValue reverses EscapeTagValue, an invalid escape keeps the
+// escaped character and a trailing backslash is dropped
+func UnescapeTagValue(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+	b.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+
+		i++
+		if i == len(value) {
+			break
+		}
+		if unescaped, ok := tagUnescapes[value[i]]; ok {
+			b.WriteByte(unescaped)
+		} else {
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// ParseTags parses the tags section of a message, without the leading @
+func ParseTags(raw string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(raw, ";") {
+		if tag == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(tag, "=")
+		tags[key] = UnescapeTagValue(value)
+	}
+	return tags
+}
+
+// FormatTags serializes tags sorted by key, without the leading @
+func FormatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var b strings.Builder
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(';')
+		}
+		b.WriteString(key)
+		if value := tags[key]; value != "" {
+			b.WriteByte('=')
+			b.WriteString(EscapeTagValue(value))
+		}
+	}
+	return b.String()
+}
+
+// IsClientOnlyTag reports whether the tag is a client-only tag such as +typing
+func IsClientOnlyTag(key string) bool {
+	return strings.HasPrefix(key, "+")
+}
+
+// TagVendor returns the vendor of a tag key, "draft" for +draft/reply
+func TagVendor(key string) string {
+	key = strings.TrimPrefix(key, "+")
+	if vendor, _, ok := strings.Cut(key, "/"); ok {
+		return vendor
+	}
+	return ""
+}
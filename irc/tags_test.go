@@ -0,0 +1,61 @@
+package irc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagValueEscaping(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     string
+		escaped string
+	}{
+		{name: "plain", raw: "hello", escaped: "hello"},
+		{name: "semicolon", raw: "a;b", escaped: `a\:b`},
+		{name: "space", raw: "a b", escaped: `a\sb`},
+		{name: "backslash", raw: `a\b`, escaped: `a\\b`},
+		{name: "crlf", raw: "a\r\nb", escaped: `a\r\nb`},
+		{name: "all", raw: "; \\\r\n", escaped: `\:\s\\\r\n`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.escaped, EscapeTagValue(c.raw))
+			assert.Equal(t, c.raw, UnescapeTagValue(c.escaped))
+		})
+	}
+
+	// lenient unescaping
+	assert.Equal(t, "ab", UnescapeTagValue(`\a\b`))
+	assert.Equal(t, "ab", UnescapeTagValue(`ab\`))
+}
+
+func TestParseMessageTags(t *testing.T) {
+	msg, err := ParseMessage(`@time=2025-01-01T00:00:00.000Z;+draft/reply=abc;example.com/key=a\sb\:c;flag :nick!user@host PRIVMSG #chan :hi`)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"time":            "2025-01-01T00:00:00.000Z",
+		"+draft/reply":    "abc",
+		"example.com/key": "a b;c",
+		"flag":            "",
+	}, msg.Tags)
+	assert.Equal(t, "#chan", msg.Target)
+	assert.Equal(t, "hi", msg.Trailing)
+}
+
+func TestFormatTags(t *testing.T) {
+	assert.Equal(t, `+typing=active;a;b=x\sy`, FormatTags(map[string]string{"b": "x y", "a": "", "+typing": "active"}))
+	assert.Equal(t, "", FormatTags(nil))
+}
+
+func TestTagKeys(t *testing.T) {
+	assert.True(t, IsClientOnlyTag("+typing"))
+	assert.False(t, IsClientOnlyTag("time"))
+	assert.Equal(t, "draft", TagVendor("+draft/reply"))
+	assert.Equal(t, "example.com", TagVendor("example.com/key"))
+	assert.Equal(t, "", TagVendor("time"))
+}